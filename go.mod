@@ -1,14 +1,24 @@
 module github.com/QuestScreen/qs-build
 
-go 1.12
+// go/packages from golang.org/x/tools requires Go 1.22; earlier versions of
+// golang.org/x/tools do not compile with current Go releases.
+go 1.22.0
 
 require (
 	github.com/QuestScreen/api v0.3.1-0.20210428171433-ca9199676fb2
+	github.com/andybalholm/brotli v1.1.0
 	github.com/fatih/color v1.10.0
-	github.com/go-ole/go-ole v1.2.5 // indirect
 	github.com/jessevdk/go-flags v1.5.0
-	github.com/veandco/go-sdl2 v0.4.5 // indirect
-	golang.org/x/mod v0.3.0
-	golang.org/x/sys v0.0.0-20210403161142-5e06dd20ab57 // indirect
+	github.com/tdewolff/minify/v2 v2.20.37
+	golang.org/x/mod v0.22.0
+	golang.org/x/tools v0.27.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
+
+require (
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	github.com/tdewolff/parse/v2 v2.7.15 // indirect
+	golang.org/x/sync v0.9.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
+)
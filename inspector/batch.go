@@ -0,0 +1,40 @@
+package inspector
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// Module is a module whose configuration is inspected by InspectConfigs.
+type Module struct {
+	// Name is the module's package name, ID the identifier used for the
+	// generated config descriptor.
+	Name, ID      string
+	DefaultConfig interface{}
+}
+
+// Result is the outcome of inspecting a single module's configuration.
+// Exactly one of Code and Error is set.
+type Result struct {
+	ID    string `json:"id"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// InspectConfigs inspects the default configuration of each given module like
+// InspectConfig does and writes the results to stdout as JSON array of Result.
+// A module whose configuration cannot be mapped does not stop the inspection of
+// the others; the returned error only reports failure to write the results.
+func InspectConfigs(modules ...Module) error {
+	results := make([]Result, 0, len(modules))
+	for _, m := range modules {
+		var code strings.Builder
+		if err := inspect(&code, m.Name, m.ID, typeOf(m.DefaultConfig)); err != nil {
+			results = append(results, Result{ID: m.ID, Error: err.Error()})
+		} else {
+			results = append(results, Result{ID: m.ID, Code: code.String()})
+		}
+	}
+	return json.NewEncoder(os.Stdout).Encode(results)
+}
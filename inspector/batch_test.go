@@ -0,0 +1,63 @@
+package inspector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInspectConfigs(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = InspectConfigs(
+		Module{Name: "flat", ID: "qmod1", DefaultConfig: &flatConfig{}},
+		Module{Name: "broken", ID: "qmod2", DefaultConfig: &valueItemConfig{}},
+		Module{Name: "nested", ID: "qmod3", DefaultConfig: &nestedConfig{}},
+	)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []Result
+	if err = json.Unmarshal(output, &results); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", results)
+	}
+	// a broken module does not stop the inspection of the others.
+	for i, id := range []string{"qmod1", "qmod2", "qmod3"} {
+		if results[i].ID != id {
+			t.Errorf("result %d: expected ID %s, got %s", i, id, results[i].ID)
+		}
+	}
+	if results[1].Code != "" || !strings.HasPrefix(results[1].Error,
+		"module broken: config field Section.Font: config item") {
+		t.Errorf("unexpected result of broken module: %+v", results[1])
+	}
+	for i, expected := range map[int][]string{
+		0: {`p1.NewFontSelect "Font"`, `p1.NewBackgroundSelect "Background"`},
+		2: {`p1.NewFontSelect "Header.Typeface"`,
+			`p1.NewBackgroundSelect "Header.Frame.Background"`, `p1.NewFontSelect "Title"`},
+	} {
+		if results[i].Error != "" {
+			t.Errorf("%s: unexpected error: %s", results[i].ID, results[i].Error)
+		}
+		if actual := generatedItems(results[i].Code); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected items %q, got %q", results[i].ID, expected, actual)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package inspector
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -12,6 +14,7 @@ import (
 
 type configField struct {
 	Label, ConstructorName string
+	order                  int
 }
 
 type importData struct {
@@ -42,58 +45,192 @@ var ConfigDescriptor{{.ModID}} = []modules.ConfigItem{
 	{{- range .Items}}
 	{
 		Constructor: {{.ConstructorName}},
-		Name: "{{.Label}}",
+		Name: {{printf "%q" .Label}},
 	},
 	{{- end}}
 }
 `))
 
-// InspectConfig uses reflection to inspect a default configuration value
-// and writes code for loading a configuration with that value to stdout.
-func InspectConfig(modName, modID string, confValue interface{}) error {
-	rValue := reflect.ValueOf(confValue)
-	rType := rValue.Type()
-	if rType.Kind() == reflect.Ptr {
-		rValue = rValue.Elem()
-		rType = rValue.Type()
+// FieldError describes a configuration field that cannot be mapped to a
+// configuration item.
+type FieldError struct {
+	Module string
+	// Path is the dotted path to the field, starting at the configuration
+	// struct.
+	Path   string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("module %v: config field %v: %v", e.Module, e.Path, e.Reason)
+}
+
+var itemType = reflect.TypeOf((*config.Item)(nil)).Elem()
+
+// configType is the view on a configuration type the inspector needs. It is
+// implemented with reflection by reflectType and with go/types by staticType.
+// Values must be comparable.
+type configType interface {
+	String() string
+	isPtr() bool
+	isStruct() bool
+	// elem returns the base type of a pointer type.
+	elem() configType
+	numField() int
+	field(i int) (name string, tag reflect.StructTag, fType configType)
+	// isItem reports whether the type implements config.Item.
+	isItem() bool
+	// ptrIsItem reports whether a pointer to the type implements config.Item.
+	ptrIsItem() bool
+	pkgPath() string
+	name() string
+}
+
+type reflectType struct {
+	reflect.Type
+}
+
+func (t reflectType) isPtr() bool      { return t.Kind() == reflect.Ptr }
+func (t reflectType) isStruct() bool   { return t.Kind() == reflect.Struct }
+func (t reflectType) elem() configType { return reflectType{t.Elem()} }
+func (t reflectType) numField() int    { return t.NumField() }
+func (t reflectType) isItem() bool     { return t.Implements(itemType) }
+func (t reflectType) ptrIsItem() bool  { return reflect.PtrTo(t.Type).Implements(itemType) }
+func (t reflectType) pkgPath() string  { return t.PkgPath() }
+func (t reflectType) name() string     { return t.Name() }
+
+func (t reflectType) field(i int) (string, reflect.StructTag, configType) {
+	field := t.Field(i)
+	return field.Name, field.Tag, reflectType{field.Type}
+}
+
+type inspector struct {
+	modName string
+	data    configData
+	// structs that are currently being inspected, to detect recursion.
+	visiting map[configType]bool
+}
+
+func (i *inspector) addItem(label string, tag fieldTag, fType configType) {
+	webPath := tag.web
+	if webPath == "" {
+		pkgPathElms := strings.Split(fType.pkgPath(), "/")
+		webPath = strings.Join(append(pkgPathElms[:len(pkgPathElms)-1], "web",
+			pkgPathElms[len(pkgPathElms)-1]), "/")
 	}
-	if rType.Kind() != reflect.Struct {
-		return fmt.Errorf("module %v: default configuration is not a struct", modName)
+
+	var importD importData
+	for _, item := range i.data.Imports {
+		if item.Path == webPath {
+			importD = item
+			break
+		}
 	}
+	if importD.Name == "" {
+		importD = importData{Name: fmt.Sprintf("p%v", len(i.data.Imports)+1),
+			Path: webPath}
+		i.data.Imports = append(i.data.Imports, importD)
+	}
+	i.data.Items = append(i.data.Items, configField{Label: label,
+		ConstructorName: importD.Name + ".New" + fType.name(), order: tag.order})
+}
 
-	data := configData{ModID: modID}
-	for i := 0; i < rType.NumField(); i++ {
-		field := rType.Field(i)
-		fVal := rValue.Field(i)
-		fType := field.Type
-		if fType.Kind() != reflect.Ptr {
-			return fmt.Errorf("module %v: config field %v does not have pointer type", modName, field.Name)
+// inspectStruct adds the config items of all fields in cType. Fields of
+// nested structs are added with the dotted path to them as label.
+// pathPrefix and labelPrefix are prepended to the fields' path and label,
+// order is the default order for the fields' items.
+func (i *inspector) inspectStruct(cType configType, pathPrefix, labelPrefix string, order int) error {
+	if i.visiting[cType] {
+		return &FieldError{Module: i.modName, Path: strings.TrimSuffix(pathPrefix, "."),
+			Reason: "recursive configuration struct " + cType.String()}
+	}
+	i.visiting[cType] = true
+	defer delete(i.visiting, cType)
+
+	for j := 0; j < cType.numField(); j++ {
+		name, structTag, fType := cType.field(j)
+		path := pathPrefix + name
+		tag, err := parseFieldTag(name, structTag)
+		if err != nil {
+			return &FieldError{Module: i.modName, Path: path, Reason: err.Error()}
 		}
-		fType = fType.Elem()
-		if _, ok := fVal.Interface().(config.Item); !ok {
-			return fmt.Errorf("module %v: config field %v is not a config.Item", modName, field.Name)
+		if tag.omit {
+			continue
 		}
-		pkgPathElms := strings.Split(fType.PkgPath(), "/")
-		webPath := strings.Join(append(pkgPathElms[:len(pkgPathElms)-1], "web",
-			pkgPathElms[len(pkgPathElms)-1]), "/")
-
-		var importD importData
-		for _, item := range data.Imports {
-			if item.Path == webPath {
-				importD = item
-				break
-			}
+		if !tag.hasOrder {
+			tag.order = order
 		}
-		if importD.Name == "" {
-			importD = importData{Name: fmt.Sprintf("p%v", len(data.Imports)+1),
-				Path: webPath}
-			data.Imports = append(data.Imports, importD)
+		label := labelPrefix + tag.label
+		isStruct := fType.isStruct() || (fType.isPtr() && fType.elem().isStruct())
+		switch {
+		case fType.isPtr() && fType.isItem():
+			// pointer to item
+			i.addItem(label, tag, fType.elem())
+		case !fType.isPtr() && fType.ptrIsItem():
+			// a nil item inherits the value of the parent configuration, which
+			// an item value cannot express.
+			return &FieldError{Module: i.modName, Path: path, Reason: "config item " +
+				fType.String() + " must be a pointer so that it can be nil to inherit its value; use *" +
+				fType.String()}
+		case isStruct && tag.web != "":
+			return &FieldError{Module: i.modName, Path: path,
+				Reason: "qs tag option web is not allowed on nested structs"}
+		case isStruct:
+			if fType.isPtr() {
+				fType = fType.elem()
+			}
+			if err := i.inspectStruct(fType, path+".", label+".", tag.order); err != nil {
+				return err
+			}
+		default:
+			return &FieldError{Module: i.modName, Path: path, Reason: "type " +
+				fType.String() + " is neither a config.Item nor a struct"}
 		}
-		item := configField{Label: field.Name, ConstructorName: importD.Name + ".New" + fType.Name()}
-		data.Items = append(data.Items, item)
 	}
-	if err := tmpl.Execute(os.Stdout, data); err != nil {
+	return nil
+}
+
+// inspect writes code for loading a configuration of type cType to w.
+func inspect(w io.Writer, modName, modID string, cType configType) error {
+	if cType != nil && cType.isPtr() {
+		cType = cType.elem()
+	}
+	if cType == nil || !cType.isStruct() {
+		return fmt.Errorf("module %v: default configuration is not a struct", modName)
+	}
+
+	i := inspector{modName: modName, data: configData{ModID: modID},
+		visiting: make(map[configType]bool)}
+	if err := i.inspectStruct(cType, "", "", 0); err != nil {
+		return err
+	}
+	sort.SliceStable(i.data.Items, func(a, b int) bool {
+		return i.data.Items[a].order < i.data.Items[b].order
+	})
+	if err := tmpl.Execute(w, i.data); err != nil {
 		return fmt.Errorf("module %v: %v", modName, err.Error())
 	}
 	return nil
 }
+
+// InspectConfig uses reflection to inspect a default configuration value
+// and writes code for loading a configuration with that value to stdout.
+//
+// Each field of the configuration must be a pointer to a config.Item, which is
+// nil if the value is inherited from the parent configuration, or a nested
+// struct (or pointer to struct) that again contains config items. Items in
+// nested structs are named by the dotted path to them; they are inherited
+// individually like the items at the top level.
+// The generated items can be customized with the `qs` struct tag on the
+// configuration's fields, see fieldTag.
+// If a field cannot be mapped, the returned error is a *FieldError.
+func InspectConfig(modName, modID string, confValue interface{}) error {
+	return inspect(os.Stdout, modName, modID, typeOf(confValue))
+}
+
+func typeOf(confValue interface{}) configType {
+	if rType := reflect.TypeOf(confValue); rType != nil {
+		return reflectType{rType}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package inspector
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/QuestScreen/api/config"
+)
+
+// generatedItem matches an item of the generated config descriptor.
+var generatedItem = regexp.MustCompile(`Constructor: ([\w.]+),\s*Name: ("[^"]*")`)
+
+// generatedItems returns the items of a generated config descriptor as
+// `<constructor> <quoted name>`.
+func generatedItems(code string) []string {
+	var ret []string
+	for _, m := range generatedItem.FindAllStringSubmatch(code, -1) {
+		ret = append(ret, m[1]+" "+m[2])
+	}
+	return ret
+}
+
+type flatConfig struct {
+	Font       *config.FontSelect
+	Background *config.BackgroundSelect
+}
+
+type taggedConfig struct {
+	Font       *config.FontSelect       `qs:"label='Font, main',order=2"`
+	Background *config.BackgroundSelect `qs:"order=1"`
+	Internal   *config.FontSelect       `qs:"hidden"`
+	Custom     *config.FontSelect       `qs:"web=example.com/items"`
+}
+
+type nestedConfig struct {
+	Title  *config.FontSelect
+	Header struct {
+		Font *config.FontSelect `qs:"label=Typeface"`
+		Box  *struct {
+			Background *config.BackgroundSelect
+		} `qs:"label=Frame"`
+	} `qs:"order=-1"`
+}
+
+type valueItemConfig struct {
+	Section struct {
+		Font config.FontSelect
+	}
+}
+
+type recursiveConfig struct {
+	Font  *config.FontSelect
+	Child *recursiveConfig
+}
+
+type webOnStructConfig struct {
+	Section struct {
+		Font *config.FontSelect
+	} `qs:"web=example.com/items"`
+}
+
+func TestInspect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		value    interface{}
+		expected []string
+	}{
+		{"flat", &flatConfig{}, []string{
+			`p1.NewFontSelect "Font"`, `p1.NewBackgroundSelect "Background"`}},
+		{"not a pointer", flatConfig{}, []string{
+			`p1.NewFontSelect "Font"`, `p1.NewBackgroundSelect "Background"`}},
+		{"tags", &taggedConfig{}, []string{
+			`p2.NewFontSelect "Custom"`, `p1.NewBackgroundSelect "Background"`,
+			`p1.NewFontSelect "Font, main"`}},
+		{"nested", &nestedConfig{}, []string{
+			`p1.NewFontSelect "Header.Typeface"`,
+			`p1.NewBackgroundSelect "Header.Frame.Background"`,
+			`p1.NewFontSelect "Title"`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var code strings.Builder
+			if err := inspect(&code, "mod", "qmod1", typeOf(tc.value)); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(code.String(), "var ConfigDescriptorqmod1 = ") ||
+				!strings.Contains(code.String(), `p1 "github.com/QuestScreen/api/web/config"`) {
+				t.Errorf("unexpected code:\n%s", code.String())
+			}
+			if actual := generatedItems(code.String()); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected items %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInspectErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		value     interface{}
+		path, msg string
+	}{
+		{"value item", &valueItemConfig{}, "Section.Font",
+			"must be a pointer so that it can be nil to inherit its value"},
+		{"no item", &struct{ Size *int }{}, "Size",
+			"type *int is neither a config.Item nor a struct"},
+		{"recursive", &recursiveConfig{}, "Child",
+			"recursive configuration struct inspector.recursiveConfig"},
+		{"web on struct", &webOnStructConfig{}, "Section",
+			"qs tag option web is not allowed on nested structs"},
+		{"invalid tag", &struct {
+			Font *config.FontSelect `qs:"order=x"`
+		}{}, "Font", "order must be an integer"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := inspect(&strings.Builder{}, "mod", "qmod1", typeOf(tc.value))
+			fieldErr, ok := err.(*FieldError)
+			if !ok {
+				t.Fatalf("expected *FieldError, got %v", err)
+			}
+			if fieldErr.Module != "mod" || fieldErr.Path != tc.path ||
+				!strings.Contains(fieldErr.Reason, tc.msg) {
+				t.Errorf("expected error at %s containing `%s`, got %v", tc.path, tc.msg, err)
+			}
+		})
+	}
+
+	for _, value := range []interface{}{nil, 42, new(string)} {
+		err := inspect(&strings.Builder{}, "mod", "qmod1", typeOf(value))
+		if err == nil || err.Error() != "module mod: default configuration is not a struct" {
+			t.Errorf("%#v: unexpected error: %v", value, err)
+		}
+	}
+}
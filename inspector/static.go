@@ -0,0 +1,75 @@
+package inspector
+
+import (
+	"go/types"
+	"io"
+	"reflect"
+)
+
+type staticType struct {
+	types.Type
+	// item is the config.Item interface.
+	item *types.Interface
+}
+
+func (t staticType) String() string {
+	return types.TypeString(t.Type, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+func (t staticType) isPtr() bool {
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+func (t staticType) isStruct() bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+func (t staticType) elem() configType {
+	return staticType{t.Underlying().(*types.Pointer).Elem(), t.item}
+}
+
+func (t staticType) numField() int {
+	return t.Underlying().(*types.Struct).NumFields()
+}
+
+func (t staticType) field(i int) (string, reflect.StructTag, configType) {
+	s := t.Underlying().(*types.Struct)
+	field := s.Field(i)
+	return field.Name(), reflect.StructTag(s.Tag(i)), staticType{field.Type(), t.item}
+}
+
+func (t staticType) isItem() bool {
+	return types.Implements(t.Type, t.item)
+}
+
+func (t staticType) ptrIsItem() bool {
+	return types.Implements(types.NewPointer(t.Type), t.item)
+}
+
+func (t staticType) pkgPath() string {
+	if named, ok := t.Type.(*types.Named); ok && named.Obj().Pkg() != nil {
+		return named.Obj().Pkg().Path()
+	}
+	return ""
+}
+
+func (t staticType) name() string {
+	if named, ok := t.Type.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// InspectType is the static counterpart of InspectConfig: It inspects the
+// type of a default configuration as determined by go/types and writes code
+// for loading a configuration of that type to w.
+// item must be the underlying interface of config.Item as seen by the type
+// checker that produced confType.
+func InspectType(w io.Writer, modName, modID string, confType types.Type,
+	item *types.Interface) error {
+	return inspect(w, modName, modID, staticType{confType, item})
+}
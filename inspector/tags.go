@@ -0,0 +1,116 @@
+package inspector
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldTag holds the options given in a field's `qs` struct tag.
+//
+// The tag is a comma-separated list of options:
+//
+//	label=<text>   the name displayed for the item (default: field name).
+//	               Enclose the text in single quotes if it contains a comma;
+//	               a single quote inside quotes is written as ''.
+//	order=<int>    items are stably sorted by this value (default: 0)
+//	omit, hidden   do not generate a config item for the field
+//	web=<path>     import path of the package containing the web constructor
+//	               New<Type> (default: <pkg>/web/<name> for <pkg>/<name>)
+//
+// `qs:"-"` is equivalent to `qs:"omit"`.
+type fieldTag struct {
+	label, web string
+	order      int
+	hasOrder   bool
+	omit       bool
+}
+
+func parseFieldTag(fieldName string, structTag reflect.StructTag) (fieldTag, error) {
+	ret := fieldTag{label: fieldName}
+	tag, ok := structTag.Lookup("qs")
+	if !ok {
+		return ret, nil
+	}
+	if tag == "-" {
+		ret.omit = true
+		return ret, nil
+	}
+	options, err := splitTagOptions(tag)
+	if err != nil {
+		return ret, err
+	}
+	for _, option := range options {
+		key, value := option, ""
+		if idx := strings.IndexByte(option, '='); idx >= 0 {
+			key, value = strings.TrimSpace(option[:idx]), strings.TrimSpace(option[idx+1:])
+		}
+		if strings.HasPrefix(value, "'") {
+			value = strings.Replace(value[1:len(value)-1], "''", "'", -1)
+		}
+		switch key {
+		case "label":
+			if value == "" {
+				return ret, errors.New("invalid qs tag: empty label")
+			}
+			ret.label = value
+		case "order":
+			order, err := strconv.Atoi(value)
+			if err != nil {
+				return ret, errors.New("invalid qs tag: order must be an integer: " + value)
+			}
+			ret.order, ret.hasOrder = order, true
+		case "omit", "hidden":
+			if value != "" {
+				return ret, errors.New("invalid qs tag: " + key + " does not take a value")
+			}
+			ret.omit = true
+		case "web":
+			if value == "" {
+				return ret, errors.New("invalid qs tag: empty web import path")
+			}
+			ret.web = value
+		default:
+			return ret, errors.New("invalid qs tag: unknown option `" + key + "`")
+		}
+	}
+	return ret, nil
+}
+
+// splitTagOptions splits a qs tag at commas that are not inside single-quoted
+// values. Options are trimmed; quoted values are returned including their
+// quotes.
+func splitTagOptions(tag string) ([]string, error) {
+	var options []string
+	start, quoted := 0, false
+	for i := 0; i < len(tag); i++ {
+		switch tag[i] {
+		case '\'':
+			if !quoted {
+				// only a value may be quoted.
+				value := strings.TrimSpace(tag[start:i])
+				if !strings.HasSuffix(value, "=") {
+					return nil, errors.New("invalid qs tag: unexpected ' in `" + tag + "`")
+				}
+				quoted = true
+			} else if i+1 < len(tag) && tag[i+1] == '\'' {
+				i++
+			} else {
+				quoted = false
+				if rest := strings.TrimSpace(tag[i+1:]); rest != "" && rest[0] != ',' {
+					return nil, errors.New("invalid qs tag: text after quoted value in `" + tag + "`")
+				}
+			}
+		case ',':
+			if !quoted {
+				options = append(options, strings.TrimSpace(tag[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if quoted {
+		return nil, errors.New("invalid qs tag: unterminated quote in `" + tag + "`")
+	}
+	return append(options, strings.TrimSpace(tag[start:])), nil
+}
@@ -0,0 +1,87 @@
+package inspector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldTag(t *testing.T) {
+	for _, tc := range []struct {
+		name, tag string
+		expected  fieldTag
+		err       string
+	}{
+		{"no tag", ``, fieldTag{label: "Field"}, ""},
+		{"other tags", `json:"field"`, fieldTag{label: "Field"}, ""},
+		{"label", `qs:"label=Font Size"`, fieldTag{label: "Font Size"}, ""},
+		{"quoted label", `qs:"label='Size, in px',order=2"`,
+			fieldTag{label: "Size, in px", order: 2, hasOrder: true}, ""},
+		{"escaped quote", `qs:"label='Player''s Name'"`, fieldTag{label: "Player's Name"}, ""},
+		{"only escaped quote", `qs:"label=''''"`, fieldTag{label: "'"}, ""},
+		{"spaces", `qs:" label = 'a' , order = -1 "`,
+			fieldTag{label: "a", order: -1, hasOrder: true}, ""},
+		{"order zero", `qs:"order=0"`, fieldTag{label: "Field", hasOrder: true}, ""},
+		{"omit", `qs:"omit"`, fieldTag{label: "Field", omit: true}, ""},
+		{"hidden", `qs:"hidden,label=x"`, fieldTag{label: "x", omit: true}, ""},
+		{"dash", `qs:"-"`, fieldTag{label: "Field", omit: true}, ""},
+		{"web", `qs:"web=example.com/plugin/web/items"`,
+			fieldTag{label: "Field", web: "example.com/plugin/web/items"}, ""},
+		{"empty label", `qs:"label="`, fieldTag{},
+			"invalid qs tag: empty label"},
+		{"empty quoted label", `qs:"label=''"`, fieldTag{},
+			"invalid qs tag: empty label"},
+		{"invalid order", `qs:"order=first"`, fieldTag{},
+			"invalid qs tag: order must be an integer: first"},
+		{"omit with value", `qs:"omit=true"`, fieldTag{},
+			"invalid qs tag: omit does not take a value"},
+		{"empty web", `qs:"web="`, fieldTag{},
+			"invalid qs tag: empty web import path"},
+		{"unknown option", `qs:"lable=x"`, fieldTag{},
+			"invalid qs tag: unknown option `lable`"},
+		{"quote in key", `qs:"'label'=x"`, fieldTag{},
+			"invalid qs tag: unexpected ' in `'label'=x`"},
+		{"quote inside value", `qs:"label=a'b'"`, fieldTag{},
+			"invalid qs tag: unexpected ' in `label=a'b'`"},
+		{"text after quote", `qs:"label='a'b"`, fieldTag{},
+			"invalid qs tag: text after quoted value in `label='a'b`"},
+		{"unterminated quote", `qs:"label='a,order=1"`, fieldTag{},
+			"invalid qs tag: unterminated quote in `label='a,order=1`"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseFieldTag("Field", reflect.StructTag(tc.tag))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("expected error `%s`, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSplitTagOptions(t *testing.T) {
+	for _, tc := range []struct {
+		tag      string
+		expected []string
+	}{
+		{"omit", []string{"omit"}},
+		{"a, b ,c", []string{"a", "b", "c"}},
+		{"label='a,b',order=1", []string{"label='a,b'", "order=1"}},
+		{"label='it''s, ok' , web=x", []string{"label='it''s, ok'", "web=x"}},
+		{"label=',', hidden", []string{"label=','", "hidden"}},
+		{"a,", []string{"a", ""}},
+	} {
+		actual, err := splitTagOptions(tc.tag)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.tag, err)
+		} else if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected %q, got %q", tc.tag, tc.expected, actual)
+		}
+	}
+}
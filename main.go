@@ -1,198 +1,120 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
-	"go/build"
-	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
+	"runtime/debug"
 	"strings"
+	"syscall"
 
+	"github.com/QuestScreen/qs-build/qsbuild"
 	flags "github.com/jessevdk/go-flags"
-	"golang.org/x/mod/modfile"
 )
 
-type command struct {
-	cmd, name, description string
-	exec                   func()
+var opts struct {
+	Verbose     bool   `short:"v" long:"verbose" description:"Show verbose debug information"`
+	Debug       bool   `short:"d" long:"debug" description:"Build an executable for debugging (includes JS source map and Go sources). Implies --web=gopherjs"`
+	Web         string `short:"w" long:"web" description:"Backend to use for the web UI. Either 'wasm' (default) or 'gopherjs'."`
+	PluginFile  string `short:"p" long:"pluginFile" description:"Path to a file that contains the import paths of all plugins you want to use" optional:"true"`
+	Binary      string `short:"b" long:"binary" description:"use with 'release' to build a binary release. Value specifies platform. Currently, only 'windows' is supported."`
+	Jobs        int    `short:"j" long:"jobs" description:"Number of parallel jobs for inspecting module configurations statically and for minifying and compressing assets. Defaults to the number of CPUs."`
+	Force       bool   `short:"f" long:"force" description:"Run all phases even if their inputs did not change since their last successful run"`
+	Run         bool   `short:"r" long:"run" description:"use with 'watch' to start questscreen after the build and restart it after each rebuild"`
+	DevAssets   bool   `short:"a" long:"dev-assets" description:"Generate an assets package that loads the assets from disk at runtime, so that changed assets do not require compiling the main app again"`
+	Optimize    bool   `short:"O" long:"optimize" description:"Minify CSS, JavaScript and SVG assets and pre-compress large assets with brotli and gzip"`
+	Fingerprint bool   `long:"fingerprint" description:"Rename assets to contain a hash of their content and generate a manifest mapping the original paths to the new ones"`
+	Collisions  string `long:"asset-collisions" default:"warn" description:"How to handle asset paths provided by more than one source: 'error', 'warn' or 'override'"`
+	AssetOrder  string `long:"asset-order" description:"Comma-separated asset sources from lowest to highest precedence for colliding paths: 'api', 'web', 'plugins' and plugin IDs. Defaults to 'api,web,plugins'"`
+	Addr        string `long:"addr" default:"localhost:8080" description:"use with 'serve' to set the address the web UI is served on"`
 }
 
-var goCmd, goBin, apiImport, goModPath, goSumPath string
-var goModStat, goSumStat os.FileInfo
-var goModContent, goSumContent []byte
-var externalPlugins bool
-
-// findQuestScreenModule checks if cwd contains the QuestScreen module.
-func findQuestScreenModule() {
-	var err error
-	goModPath, err = filepath.Abs("go.mod")
-	must(err, "failed to find go.mod:")
-	goModStat, err = os.Stat(goModPath)
-	must(err)
-	goModContent, err = ioutil.ReadFile(goModPath)
-	if err == nil {
-		goSumPath, err = filepath.Abs("go.sum")
-		must(err)
-		goSumStat, err = os.Stat(goSumPath)
-		must(err)
-		goSumContent, err = ioutil.ReadFile(goSumPath)
-		must(err)
-
-		var mod *modfile.File
-		if mod, err = modfile.Parse("go.mod", goModContent, nil); err != nil {
-			logWarning("unable to parse go.mod: %v", err.Error())
-		} else {
-			if mod != nil && mod.Module != nil && mod.Module.Mod.Path == "github.com/QuestScreen/QuestScreen" {
-				for _, r := range mod.Require {
-					if r.Mod.Path == "github.com/QuestScreen/api" {
-						apiImport = "github.com/QuestScreen/api"
-						if r.Mod.Version != "" {
-							apiImport += "@" + r.Mod.Version
-						}
-						return
-					}
-				}
-				logError("failed to find reference to github.com/QuestScreen/api in go.mod")
-				finalize(true)
-			}
-		}
-	} else {
-		if !os.IsNotExist(err) {
-			logWarning("while reading go.mod: %v", err.Error())
-		}
-	}
-
-	logError("current directory is not QuestScreen source directory!")
-	finalize(true)
-}
-
-func finalize(exitError bool) {
-	if externalPlugins {
-		ioutil.WriteFile(goModPath, goModContent, goModStat.Mode())
-		ioutil.WriteFile(goSumPath, goSumContent, goSumStat.Mode())
-	}
-	if exitError {
-		os.Exit(1)
-	}
-}
-
-func parsePluginsFile(path string) {
-	logPhase("Init")
-
-	pFile, err := os.Open(path)
-	must(err)
-	defer pFile.Close()
-	reader := bufio.NewReader(pFile)
-	var line string
-	lineCount := 0
-	for err != io.EOF {
-		line, err = reader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			break
-		}
-		lineCount++
-		if line = strings.TrimSpace(line); line == "" {
-			continue
-		}
-		items := strings.Split(line, " ")
-		descr := pluginDescr{line: lineCount}
-		if idx := strings.IndexByte(items[len(items)-1], '@'); idx >= 0 {
-			descr.version = items[len(items)-1][idx+1:]
-			items[len(items)-1] = items[len(items)-1][:idx]
-		}
-		switch len(items) {
-		case 0:
-			continue
-		case 1:
-			descr.importPath = items[0]
-			descr.id = filepath.Base(descr.importPath)
-		case 2:
-			descr.importPath = items[1]
-			descr.id = items[0]
-		default:
-			panic(fmt.Sprintf("%s(%v): too many items in line", opts.PluginFile, lineCount))
-		}
-		var getPath string
-		if descr.version == "" {
-			getPath = descr.importPath
-		} else {
-			getPath = fmt.Sprintf("%s@%s", descr.importPath, descr.version)
-		}
-		logInfo(fmt.Sprintf("loading plugin '%s' at \"%s\"", descr.id, getPath))
-		runAndCheck(exec.Command(goCmd, "get", "-u", getPath), func(err error, stderr string) {
-			logError(err.Error())
-			writeErrorLines(stderr)
-		})
-		externalPlugins = true
-
-		descr.dir = runAndCheck(exec.Command(goCmd, "list", "-m", "-f", "{{.Dir}}", descr.importPath), func(err error, stderr string) {
-			logError(err.Error())
-			writeErrorLines(stderr)
-		})
-
-		opts.chosenPlugins = append(opts.chosenPlugins, descr)
-	}
+type command struct {
+	cmd, description string
+	exec             func(b *qsbuild.Builder) error
+	// explicit commands are only executed when given on the command line.
+	explicit bool
 }
 
 var commands = []command{
-	{cmd: "deps", name: "Build Dependencies",
+	{cmd: "deps",
 		description: "Ensures that all dependencies required for building QuestScreen are available",
-		exec:        ensureDepsAvailable},
-	{cmd: "plugins", name: "Plugins",
-		description: "walks the plugins directory and discovers all plugins there. Writes code for loading the plugins in web UI and main app",
-		exec:        writePluginLoaders},
-	{cmd: "webui", name: "Web UI", description: "compiles web UI to assets/main.js",
-		exec: buildWebUI},
-	{cmd: "assets", name: "Assets", description: "packages all web files in assets/ into assets/assets.go",
-		exec: packAssets},
-	{cmd: "compile", name: "Compile", description: "compiles main app",
-		exec: compileQuestscreen},
+		exec:        (*qsbuild.Builder).Deps},
+	{cmd: "validate",
+		description: "checks the plugin manifests and template references without generating any code",
+		exec:        (*qsbuild.Builder).Validate, explicit: true},
+	{cmd: "plugins",
+		description: "walks the plugins directory and discovers all plugins there. Writes code for loading the plugins in web UI and main app. `plugins update` updates the versions in plugins.lock",
+		exec:        (*qsbuild.Builder).Plugins},
+	{cmd: "webui", description: "compiles web UI to assets/main.js",
+		exec: (*qsbuild.Builder).WebUI},
+	{cmd: "assets", description: "packages all web files in assets/ into assets/assets.go",
+		exec: (*qsbuild.Builder).Assets},
+	{cmd: "compile", description: "compiles main app",
+		exec: (*qsbuild.Builder).Compile},
+}
+
+// handleSignals interrupts the builder on SIGINT and SIGTERM so that child
+// processes are stopped and temporary files are removed before exiting.
+func handleSignals(b *qsbuild.Builder, log qsbuild.Logger) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Warning("received " + sig.String() + ", cleaning up")
+		b.Interrupt()
+		os.Exit(1)
+	}()
 }
 
 func main() {
+	log := qsbuild.NewConsoleLogger()
 	args, err := flags.Parse(&opts)
 	if flags.WroteHelp(err) {
 		os.Exit(0)
 	}
-	must(err)
-	if opts.Debug {
-		if opts.Web != "" && opts.Web != "gopherjs" {
-			logError("--debug does not allow web UI backend '%s'\n", opts.Web)
-			finalize(true)
-		}
-		opts.Web = "gopherjs"
-	}
-	if opts.Web == "" {
-		opts.Web = "wasm"
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
 	}
-	switch opts.Web {
-	case "", "wasm":
-		opts.wasm = true
-	case "gopherjs":
-		opts.wasm = false
+
+	options := qsbuild.Options{Verbose: opts.Verbose, Debug: opts.Debug,
+		Web: opts.Web, DevAssets: opts.DevAssets,
+		Optimize: opts.Optimize, Fingerprint: opts.Fingerprint, PluginFile: opts.PluginFile, Jobs: opts.Jobs, Force: opts.Force, Logger: log}
+	switch opts.Collisions {
+	case "warn":
+		options.AssetCollisions = qsbuild.CollisionWarn
+	case "error":
+		options.AssetCollisions = qsbuild.CollisionError
+	case "override":
+		options.AssetCollisions = qsbuild.CollisionOverride
 	default:
-		logError("unknown web backend: '%s'", opts.Web)
-		finalize(true)
+		log.Error("unknown value for --asset-collisions: " + opts.Collisions)
+		os.Exit(1)
+	}
+	if opts.AssetOrder != "" {
+		options.AssetOrder = strings.Split(opts.AssetOrder, ",")
 	}
-
 	commandEnabled := make([]bool, len(commands))
-	doRelease := false
+	// standalone is a command that may not be combined with others.
+	standalone := ""
 	if len(args) == 0 {
 		for i := range commandEnabled {
-			commandEnabled[i] = true
+			commandEnabled[i] = !commands[i].explicit
 		}
 	} else {
 		foundErrors := false
 		for i := range args {
+			if args[i] == "update" && i > 0 && args[i-1] == "plugins" {
+				options.UpdateLock = true
+				continue
+			}
 			found := false
 			for j := range commands {
 				if commands[j].cmd == args[i] {
 					found = true
 					if commandEnabled[j] {
-						logError("duplicate command: '%s'", args[i])
+						log.Error("duplicate command: '" + args[i] + "'")
 						foundErrors = true
 					} else {
 						commandEnabled[j] = true
@@ -201,77 +123,81 @@ func main() {
 				}
 			}
 			if !found {
-				if args[i] == "release" {
+				switch args[i] {
+				case "release", "watch", "serve":
 					if len(args) != 1 {
-						logError("cannot give other commands along with `release`")
+						log.Error("cannot give other commands along with `" + args[i] + "`")
 						foundErrors = true
 					} else {
-						doRelease = true
+						standalone = args[i]
 					}
-				} else {
-					logError("unknown command: '%s'", args[i])
+				default:
+					log.Error("unknown command: '" + args[i] + "'")
 					foundErrors = true
 				}
 			}
 		}
 		if foundErrors {
-			finalize(true)
+			os.Exit(1)
 		}
 	}
 
-	{
-		goCmd = filepath.Join(build.Default.GOROOT, "bin", "go")
-		cmd := exec.Command(goCmd, "env", "GOBIN")
-		goBin = runAndCheck(cmd, func(err error, stderr string) {
-			logError("failed to get GOBIN:")
-			logError(err.Error())
-			writeErrorLines(stderr)
-		})
-		if goBin == "" {
-			goBin = filepath.Join(build.Default.GOPATH, "bin")
-		}
-	}
-
-	findQuestScreenModule()
-
-	if doRelease {
+	var rKind qsbuild.ReleaseKind
+	if standalone == "release" {
 		switch opts.Binary {
 		case "":
-			opts.rKind = ReleaseSource
+			rKind = qsbuild.ReleaseSource
 		case "windows":
-			opts.rKind = ReleaseWindowsBinary
+			rKind = qsbuild.ReleaseWindowsBinary
 		default:
-			logError("unknown binary release platform: " + opts.Binary)
-			finalize(true)
-		}
-		release(opts.rKind)
-	} else {
-		mustCond(opts.Binary == "", "illegal value for --binary: "+opts.Binary,
-			"this option may only be given for command 'release'")
-	}
-
-	if opts.PluginFile == "" {
-		info, err := os.Stat("plugins/plugins.txt")
-		if err == nil && !info.IsDir() {
-			opts.PluginFile = "plugins/plugins.txt"
+			log.Error("unknown binary release platform: " + opts.Binary)
+			os.Exit(1)
 		}
+	} else if opts.Binary != "" {
+		log.Error("illegal value for --binary: " + opts.Binary)
+		log.Error("this option may only be given for command 'release'")
+		os.Exit(1)
 	}
-	if opts.PluginFile != "" {
-		parsePluginsFile(opts.PluginFile)
+	if opts.Run && standalone != "watch" {
+		log.Error("--run may only be given for command 'watch'")
+		os.Exit(1)
 	}
 
-	if _, err := os.Stat("assets"); err != nil {
-		if os.IsNotExist(err) {
-			os.Mkdir("assets", 0755)
-		} else {
-			must(err, "unable to create directory 'assets':")
+	b := qsbuild.New(options)
+	handleSignals(b, log)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error(fmt.Sprintf("internal error: %v", r))
+			if opts.Verbose {
+				os.Stderr.Write(debug.Stack())
+			}
+			b.Close()
+			os.Exit(1)
 		}
+	}()
+
+	if err := b.Init(); err != nil {
+		b.Close()
+		os.Exit(1)
 	}
-	for i := range commands {
-		if commandEnabled[i] {
-			logPhase(commands[i].name)
-			commands[i].exec()
+	switch standalone {
+	case "release":
+		err = b.Release(rKind)
+	case "watch":
+		err = b.Watch(qsbuild.WatchOptions{Run: opts.Run})
+	case "serve":
+		err = b.Serve(opts.Addr)
+	default:
+		for i := range commands {
+			if commandEnabled[i] {
+				if err = commands[i].exec(b); err != nil {
+					break
+				}
+			}
 		}
 	}
-	finalize(false)
+	b.Close()
+	if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,210 @@
+package qsbuild
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/QuestScreen/qs-build/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// notStaticError is returned when the type of a module's default configuration
+// cannot be determined statically. The configuration is then inspected by
+// executing an inspector.
+type notStaticError struct {
+	reason string
+}
+
+func (e notStaticError) Error() string {
+	return e.reason
+}
+
+const configImport = "github.com/QuestScreen/api/config"
+
+// findItemInterface searches pkg and its dependencies for config.Item.
+func findItemInterface(pkg *packages.Package) *types.Interface {
+	seen := make(map[*packages.Package]bool)
+	var search func(p *packages.Package) *types.Interface
+	search = func(p *packages.Package) *types.Interface {
+		if seen[p] {
+			return nil
+		}
+		seen[p] = true
+		if p.PkgPath == configImport && p.Types != nil {
+			if obj, ok := p.Types.Scope().Lookup("Item").(*types.TypeName); ok {
+				if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+					return iface
+				}
+			}
+			return nil
+		}
+		for _, dep := range p.Imports {
+			if iface := search(dep); iface != nil {
+				return iface
+			}
+		}
+		return nil
+	}
+	return search(pkg)
+}
+
+// defaultConfigValue returns the expression given for DefaultConfig in value,
+// which is assigned to Descriptor. ok is false if value is not a composite
+// literal; expr is nil if the literal does not set DefaultConfig.
+func defaultConfigValue(value ast.Expr) (expr ast.Expr, ok bool) {
+	for {
+		switch e := value.(type) {
+		case *ast.ParenExpr:
+			value = e.X
+			continue
+		case *ast.UnaryExpr:
+			if e.Op == token.AND {
+				value = e.X
+				continue
+			}
+		}
+		break
+	}
+	lit, ok := value.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+	for _, elt := range lit.Elts {
+		kv, isKV := elt.(*ast.KeyValueExpr)
+		if !isKV {
+			// positional fields
+			return nil, false
+		}
+		if key, isIdent := kv.Key.(*ast.Ident); isIdent && key.Name == "DefaultConfig" {
+			return kv.Value, true
+		}
+	}
+	return nil, true
+}
+
+// descriptorConfigType determines the type of Descriptor.DefaultConfig in
+// pkg by searching all assignments to Descriptor and its DefaultConfig field.
+func descriptorConfigType(pkg *packages.Package) (types.Type, error) {
+	obj, ok := pkg.Types.Scope().Lookup("Descriptor").(*types.Var)
+	if !ok {
+		return nil, notStaticError{"package has no variable Descriptor"}
+	}
+	isDescriptor := func(e ast.Expr) bool {
+		id, ok := e.(*ast.Ident)
+		return ok && (pkg.TypesInfo.Defs[id] == obj || pkg.TypesInfo.Uses[id] == obj)
+	}
+
+	var values []ast.Expr
+	unknown := false
+	assign := func(lhs, rhs ast.Expr) {
+		if isDescriptor(lhs) {
+			expr, ok := defaultConfigValue(rhs)
+			if !ok {
+				unknown = true
+			} else if expr != nil {
+				values = append(values, expr)
+			}
+		} else if sel, ok := lhs.(*ast.SelectorExpr); ok &&
+			sel.Sel.Name == "DefaultConfig" && isDescriptor(sel.X) {
+			values = append(values, rhs)
+		}
+	}
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.ValueSpec:
+				for i, name := range node.Names {
+					if i < len(node.Values) {
+						assign(name, node.Values[i])
+					}
+				}
+			case *ast.AssignStmt:
+				if len(node.Lhs) == len(node.Rhs) {
+					for i := range node.Lhs {
+						assign(node.Lhs[i], node.Rhs[i])
+					}
+				} else {
+					for _, lhs := range node.Lhs {
+						if isDescriptor(lhs) {
+							unknown = true
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	if unknown {
+		return nil, notStaticError{"Descriptor is not assigned a composite literal"}
+	}
+	if len(values) == 0 {
+		return nil, notStaticError{"no value for Descriptor.DefaultConfig found"}
+	}
+
+	var ret types.Type
+	for _, value := range values {
+		t := pkg.TypesInfo.TypeOf(value)
+		if t == nil || types.IsInterface(t) {
+			return nil, notStaticError{"DefaultConfig is not given a value of concrete type"}
+		}
+		if basic, ok := t.(*types.Basic); ok && basic.Kind() == types.UntypedNil {
+			return nil, notStaticError{"DefaultConfig is nil"}
+		}
+		if ret != nil && !types.Identical(ret, t) {
+			return nil, notStaticError{"DefaultConfig is assigned values of different types"}
+		}
+		ret = t
+	}
+	return ret, nil
+}
+
+// loadModulePackages loads the packages of all modules with a single call to
+// go/packages, so that packages shared by the modules are parsed and checked
+// only once. It returns the packages by import path. If loading fails, the
+// returned error is a notStaticError.
+func (b *Builder) loadModulePackages(importPaths []string) (map[string]*packages.Package, error) {
+	ret := make(map[string]*packages.Package, len(importPaths))
+	if len(importPaths) == 0 {
+		return ret, nil
+	}
+	cfg := &packages.Config{
+		Dir: b.root,
+		Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedTypesSizes | packages.NeedSyntax |
+			packages.NeedTypesInfo,
+		BuildFlags: []string{"-modfile=" + b.sessionModPath},
+	}
+	pkgs, err := packages.Load(cfg, importPaths...)
+	if err != nil {
+		return nil, notStaticError{"failed to load packages: " + err.Error()}
+	}
+	for _, pkg := range pkgs {
+		ret[pkg.PkgPath] = pkg
+	}
+	return ret, nil
+}
+
+// inspectConfigStatically generates code for loading the configuration of the
+// module whose package has been loaded by loadModulePackages from the type of
+// Descriptor.DefaultConfig, without building and running any code.
+// If the type cannot be determined, the returned error is a notStaticError.
+func (b *Builder) inspectConfigStatically(pkg *packages.Package, moduleName, moduleID string) (string, error) {
+	if len(pkg.Errors) > 0 {
+		return "", notStaticError{pkg.Errors[0].Error()}
+	}
+	item := findItemInterface(pkg)
+	if item == nil {
+		return "", notStaticError{"package does not depend on " + configImport}
+	}
+	confType, err := descriptorConfigType(pkg)
+	if err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	if err = inspector.InspectType(&code, moduleName, moduleID, confType, item); err != nil {
+		return "", err
+	}
+	return code.String(), nil
+}
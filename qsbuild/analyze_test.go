@@ -0,0 +1,232 @@
+package qsbuild
+
+import (
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// moduleFixture adds plugin modules to questScreenFixture. The QuestScreen
+// module uses the local qs-build source for the inspector program, and the
+// go.sum of qs-build, which covers github.com/QuestScreen/api.
+func moduleFixture(t *testing.T, modules map[string]string) *Builder {
+	t.Helper()
+	// the fixture must be built from the module cache.
+	t.Setenv("GOPROXY", "off")
+	source, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := ioutil.ReadFile(filepath.Join(source, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(modules))
+	changes := map[string]string{
+		"go.mod": questScreenFixture["go.mod"] + `
+require github.com/QuestScreen/qs-build v0.0.0-00010101000000-000000000000
+
+replace github.com/QuestScreen/qs-build => ` + filepath.ToSlash(source) + "\n",
+		"go.sum":     string(goSum),
+		"web/web.go": "package web\n"}
+	for name, source := range modules {
+		names = append(names, name)
+		changes["plugins/base/"+name+"/"+name+".go"] = "package " + name + `
+
+import (
+	"github.com/QuestScreen/api/config"
+	"github.com/QuestScreen/api/modules"
+)
+
+` + source
+	}
+	changes["plugins/base/questscreen-plugin.yaml"] = strings.Replace(
+		questScreenFixture["plugins/base/questscreen-plugin.yaml"], "modules: []",
+		"modules: ["+strings.Join(names, ", ")+"]", 1)
+	b := newFixture(t, changes)
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// generatedItem matches an item of a generated config descriptor.
+var generatedItem = regexp.MustCompile(`Constructor:\s+([\w.]+),\s*Name:\s+("[^"]*")`)
+
+// configItems returns the items of the config descriptor generated for the
+// module with the given ID as `<constructor> <quoted name>`.
+func configItems(t *testing.T, b *Builder, id string) []string {
+	t.Helper()
+	code, err := ioutil.ReadFile(b.path("web", "configitems"+id+".go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret []string
+	for _, m := range generatedItem.FindAllStringSubmatch(string(code), -1) {
+		ret = append(ret, m[1]+" "+m[2])
+	}
+	return ret
+}
+
+const fixtureConfig = `type Config struct {
+	Font       *config.FontSelect ` + "`qs:\"label='Font, main',order=2\"`" + `
+	Background struct {
+		Color *config.BackgroundSelect
+	} ` + "`qs:\"order=1\"`" + `
+	Internal *config.FontSelect ` + "`qs:\"-\"`" + `
+}
+`
+
+var fixtureConfigItems = []string{`p1.NewBackgroundSelect "Background.Color"`,
+	`p1.NewFontSelect "Font, main"`}
+
+func TestDescriptorConfigType(t *testing.T) {
+	modules := map[string]string{
+		"literal": fixtureConfig + `var Descriptor = modules.Module{Name: "A", DefaultConfig: &Config{}}`,
+		"assigned": fixtureConfig + `var Descriptor modules.Module
+
+func init() {
+	Descriptor.DefaultConfig = Config{}
+}`,
+		"pointer": fixtureConfig + `var Descriptor = &modules.Module{DefaultConfig: (&Config{})}`,
+		"call": fixtureConfig + `func defaults() interface{} { return &Config{} }
+
+var Descriptor = modules.Module{DefaultConfig: defaults()}`,
+		"nodefault": `var Descriptor = modules.Module{Name: "A"}
+var _ config.Item`,
+		"nodescriptor": `var Module = modules.Module{DefaultConfig: &struct{}{}}
+var _ config.Item`,
+		"nil": `var Descriptor = modules.Module{DefaultConfig: nil}
+var _ config.Item`,
+		"differing": fixtureConfig + `var Descriptor = modules.Module{DefaultConfig: &Config{}}
+
+func init() {
+	Descriptor.DefaultConfig = Config{}
+}`,
+		"unknown": fixtureConfig + `var Descriptor = makeModule()
+
+func makeModule() modules.Module { return modules.Module{DefaultConfig: &Config{}} }`,
+	}
+	b := moduleFixture(t, modules)
+	importPaths := make([]string, 0, len(modules))
+	for name := range modules {
+		importPaths = append(importPaths, "github.com/QuestScreen/QuestScreen/plugins/base/"+name)
+	}
+	pkgs, err := b.loadModulePackages(importPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		module, expected, notStatic string
+	}{
+		{"literal", "*Config", ""},
+		{"assigned", "Config", ""},
+		{"pointer", "*Config", ""},
+		{"call", "", "DefaultConfig is not given a value of concrete type"},
+		{"nodefault", "", "no value for Descriptor.DefaultConfig found"},
+		{"nodescriptor", "", "package has no variable Descriptor"},
+		{"nil", "", "DefaultConfig is nil"},
+		{"differing", "", "DefaultConfig is assigned values of different types"},
+		{"unknown", "", "Descriptor is not assigned a composite literal"},
+	} {
+		t.Run(tc.module, func(t *testing.T) {
+			pkg, ok := pkgs["github.com/QuestScreen/QuestScreen/plugins/base/"+tc.module]
+			if !ok {
+				t.Fatal("package has not been loaded")
+			}
+			if len(pkg.Errors) > 0 {
+				t.Fatal(pkg.Errors[0])
+			}
+			confType, err := descriptorConfigType(pkg)
+			if tc.notStatic != "" {
+				if _, ok := err.(notStaticError); !ok || err.Error() != tc.notStatic {
+					t.Errorf("expected notStaticError `%s`, got %v", tc.notStatic, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := types.TypeString(confType, types.RelativeTo(pkg.Types)); actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteModuleConfigLoaders(t *testing.T) {
+	b := moduleFixture(t, map[string]string{
+		"static": fixtureConfig + `var Descriptor = modules.Module{Name: "Static", DefaultConfig: &Config{}}`,
+		// cannot be determined statically, so it is inspected via reflection.
+		"dynamic": fixtureConfig + `func defaults() interface{} { return &Config{} }
+
+var Descriptor = modules.Module{Name: "Dynamic", DefaultConfig: defaults()}`,
+	})
+	var plugins Data
+	if err := b.run("Plugins", func() {
+		plugins = b.discoverAndProcess()
+		b.writeModuleConfigLoaders(plugins)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, module := range plugins[0].Modules {
+		if actual := configItems(t, b, module.ImportName); !reflect.DeepEqual(actual, fixtureConfigItems) {
+			t.Errorf("%s: expected items %q, got %q", module.Name, fixtureConfigItems, actual)
+		}
+	}
+}
+
+func TestWriteModuleConfigLoadersErrors(t *testing.T) {
+	b := moduleFixture(t, map[string]string{
+		"static": `type Config struct {
+	Section struct {
+		Font config.FontSelect
+	}
+}
+
+var Descriptor = modules.Module{DefaultConfig: &Config{}}`,
+		"dynamic": `type Config struct {
+	Size *int
+}
+
+func defaults() interface{} { return &Config{} }
+
+var Descriptor = modules.Module{DefaultConfig: defaults()}
+var _ config.Item`,
+	})
+	var log strings.Builder
+	b.log = recordingLogger{testLogger{t}, &log, false}
+	checkPhaseError(t, b.run("Plugins", func() {
+		b.writeModuleConfigLoaders(b.discoverAndProcess())
+	}), "Plugins", "")
+	// errors of statically and dynamically inspected modules are reported.
+	for _, expected := range []string{
+		"module static: config field Section.Font: config item config.FontSelect must be a pointer",
+		"module dynamic: config field Size: type *int is neither a config.Item nor a struct",
+		"failed to inspect module configurations",
+	} {
+		if !strings.Contains(log.String(), expected) {
+			t.Errorf("expected an error containing `%s`, got:\n%s", expected, log.String())
+		}
+	}
+}
+
+func TestRequireInspectorDevelopmentBuild(t *testing.T) {
+	t.Setenv("GOPROXY", "off")
+	goSum, err := ioutil.ReadFile(filepath.Join("..", "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// QuestScreen does not pin qs-build, and the test binary has no version.
+	b := newFixture(t, map[string]string{"go.sum": string(goSum)})
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	checkPhaseError(t, b.run("Plugins", b.requireInspector), "Plugins",
+		"qs-build is a development build")
+}
@@ -0,0 +1,248 @@
+package qsbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// assetRules loads the assets section of a questscreen-plugin.yaml file. All
+// patterns are globs relative to web/assets with forward slashes. Besides the
+// syntax of path.Match, they may contain "**" as path segment, which matches
+// any number of directories.
+type assetRules struct {
+	// Include selects the files to be shipped. Defaults to all files.
+	Include []string
+	// Exclude removes files selected by Include, e.g. sources like "**/*.scss".
+	Exclude []string
+	// CSS lists stylesheets in load order. Files matching an earlier pattern are
+	// loaded first; stylesheets not matching any pattern are loaded last.
+	// Files matching a pattern are stylesheets regardless of their extension.
+	CSS []string
+	// JS, Fonts and Images assign files to the respective category regardless
+	// of their extension. Other files are categorized by their extension.
+	JS, Fonts, Images []string
+}
+
+// AssetData lists the files a plugin ships in web/assets, relative to that
+// directory and with forward slashes, by category.
+type AssetData struct {
+	// CSS contains the stylesheets in load order.
+	CSS    []string
+	JS     []string
+	Fonts  []string
+	Images []string
+	Other  []string
+}
+
+// Files returns all files of all categories.
+func (a AssetData) Files() []string {
+	var ret []string
+	for _, list := range [][]string{a.CSS, a.JS, a.Fonts, a.Images, a.Other} {
+		ret = append(ret, list...)
+	}
+	return ret
+}
+
+// assetCategories maps file extensions to the category of files not assigned
+// to a category by the manifest.
+var assetCategories = map[string]string{
+	".css": "css", ".js": "js", ".mjs": "js",
+	".woff": "fonts", ".woff2": "fonts", ".ttf": "fonts", ".otf": "fonts",
+	".eot": "fonts", ".png": "images", ".jpg": "images", ".jpeg": "images",
+	".gif": "images", ".svg": "images", ".webp": "images", ".avif": "images",
+	".bmp": "images", ".ico": "images",
+}
+
+// matchGlob reports whether the slash-separated name matches the pattern as
+// described at assetRules.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// firstMatch returns the index of the first pattern matching name, or -1.
+func firstMatch(patterns []string, name string) int {
+	for i, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// listAssetFiles returns all files below the plugin's assets directory root,
+// relative to root and with forward slashes. Symlinks are followed if they
+// point to a location inside root that does not contain the link.
+func listAssetFiles(root string) ([]string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	var errs manifestErrors
+	var walk func(dir, rel string)
+	walk = func(dir, rel string) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, manifestError{path: dir, msg: err.Error()})
+			return
+		}
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			name := path.Join(rel, entry.Name())
+			isDir := entry.IsDir()
+			if entry.Mode()&os.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(p)
+				if err != nil {
+					errs = append(errs, manifestError{path: p, msg: "broken symlink"})
+					continue
+				}
+				if _, ok := relativeTo(realRoot, target); !ok {
+					errs = append(errs, manifestError{path: p,
+						msg: "symlink points outside of web/assets"})
+					continue
+				}
+				info, err := os.Stat(target)
+				if err != nil {
+					errs = append(errs, manifestError{path: p, msg: err.Error()})
+					continue
+				}
+				isDir = info.IsDir()
+				if realDir, err := filepath.EvalSymlinks(dir); isDir && err == nil {
+					if _, ok := relativeTo(target, realDir); ok {
+						errs = append(errs, manifestError{path: p,
+							msg: "symlink points to a directory containing it"})
+						continue
+					}
+				}
+			}
+			if isDir {
+				walk(p, name)
+			} else {
+				files = append(files, name)
+			}
+		}
+	}
+	walk(root, "")
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// applyAssetRules selects and categorizes the given files of the plugin whose
+// manifest is at manifestPath according to its rules. Patterns not matching
+// any file are reported as warnings since they are likely typos.
+func (b *Builder) applyAssetRules(manifestPath string, rules assetRules,
+	files []string) (AssetData, error) {
+	var selected []string
+	for _, f := range files {
+		if (len(rules.Include) == 0 || firstMatch(rules.Include, f) != -1) &&
+			firstMatch(rules.Exclude, f) == -1 {
+			selected = append(selected, f)
+		}
+	}
+
+	checkUsed := func(key string, patterns, files []string) {
+		for _, pattern := range patterns {
+			if !anyMatch(pattern, files) {
+				b.logWarning("%s: assets.%s: pattern `%s` does not match any file",
+					manifestPath, key, pattern)
+			}
+		}
+	}
+	checkUsed("include", rules.Include, files)
+	checkUsed("exclude", rules.Exclude, files)
+	checkUsed("css", rules.CSS, selected)
+	checkUsed("js", rules.JS, selected)
+	checkUsed("fonts", rules.Fonts, selected)
+	checkUsed("images", rules.Images, selected)
+
+	var data AssetData
+	var errs manifestErrors
+	cssOrder := make(map[string]int)
+	for _, f := range selected {
+		var categories []string
+		if i := firstMatch(rules.CSS, f); i != -1 {
+			categories = append(categories, "css")
+			cssOrder[f] = i
+		}
+		for _, c := range []struct {
+			name     string
+			patterns []string
+		}{{"js", rules.JS}, {"fonts", rules.Fonts}, {"images", rules.Images}} {
+			if firstMatch(c.patterns, f) != -1 {
+				categories = append(categories, c.name)
+			}
+		}
+		switch len(categories) {
+		case 0:
+			categories = append(categories, assetCategories[strings.ToLower(path.Ext(f))])
+			if categories[0] == "css" {
+				cssOrder[f] = len(rules.CSS)
+			}
+		case 1:
+		default:
+			errs = append(errs, manifestError{path: manifestPath,
+				msg: "assets: web/assets/" + f + " matches the categories " +
+					strings.Join(categories, " and ")})
+			continue
+		}
+		switch categories[0] {
+		case "css":
+			data.CSS = append(data.CSS, f)
+		case "js":
+			data.JS = append(data.JS, f)
+		case "fonts":
+			data.Fonts = append(data.Fonts, f)
+		case "images":
+			data.Images = append(data.Images, f)
+		default:
+			data.Other = append(data.Other, f)
+		}
+	}
+	if len(errs) > 0 {
+		return AssetData{}, errs
+	}
+	// files are sorted by path, which thus orders stylesheets matching the same
+	// pattern.
+	sort.SliceStable(data.CSS, func(i, j int) bool {
+		return cssOrder[data.CSS[i]] < cssOrder[data.CSS[j]]
+	})
+	return data, nil
+}
+
+// anyMatch checks whether pattern matches any of the given files.
+func anyMatch(pattern string, files []string) bool {
+	for _, f := range files {
+		if matchGlob(pattern, f) {
+			return true
+		}
+	}
+	return false
+}
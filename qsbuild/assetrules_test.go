@@ -0,0 +1,93 @@
+package qsbuild
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"style.css", "style.css", true},
+		{"*.css", "style.css", true},
+		{"*.css", "css/style.css", false},
+		{"css/*.css", "css/style.css", true},
+		{"**/*.css", "style.css", true},
+		{"**/*.css", "a/b/style.css", true},
+		{"**/*.css", "a/b/style.scss", false},
+		{"css/**", "css/a/b.css", true},
+		{"css/**", "css", true},
+		{"css/**", "fonts/a.woff", false},
+		{"a/**/b/*.png", "a/b/x.png", true},
+		{"a/**/b/*.png", "a/x/y/b/x.png", true},
+		{"a/**/b/*.png", "a/x/y/c/x.png", false},
+		{"img/?.png", "img/a.png", true},
+		{"img/[ab].png", "img/c.png", false},
+		{"img", "img/a.png", false},
+	} {
+		if actual := matchGlob(tc.pattern, tc.name); actual != tc.match {
+			t.Errorf("matchGlob(%q, %q) = %v, expected %v", tc.pattern, tc.name, actual, tc.match)
+		}
+	}
+}
+
+func TestApplyAssetRules(t *testing.T) {
+	files := []string{"a.css", "b.css", "fonts/x.woff2", "img/logo.svg", "lib/app.js",
+		"lib/theme.less", "src/a.scss", "vendor/reset.css"}
+	for _, tc := range []struct {
+		name     string
+		rules    assetRules
+		data     AssetData
+		warnings []string
+	}{
+		{name: "by extension", rules: assetRules{}, data: AssetData{
+			CSS: []string{"a.css", "b.css", "vendor/reset.css"}, JS: []string{"lib/app.js"},
+			Fonts: []string{"fonts/x.woff2"}, Images: []string{"img/logo.svg"},
+			Other: []string{"lib/theme.less", "src/a.scss"}}},
+		{name: "include and exclude", rules: assetRules{Include: []string{"*.css", "lib/**"},
+			Exclude: []string{"**/*.less"}},
+			data: AssetData{CSS: []string{"a.css", "b.css"}, JS: []string{"lib/app.js"}}},
+		{name: "css order", rules: assetRules{CSS: []string{"vendor/*.css", "lib/theme.less", "b.css"}},
+			data: AssetData{CSS: []string{"vendor/reset.css", "lib/theme.less", "b.css", "a.css"},
+				JS: []string{"lib/app.js"}, Fonts: []string{"fonts/x.woff2"},
+				Images: []string{"img/logo.svg"}, Other: []string{"src/a.scss"}}},
+		{name: "explicit categories", rules: assetRules{Include: []string{"img/**", "src/**"},
+			Images: []string{"src/*"}, JS: []string{"img/*.svg"}},
+			data: AssetData{JS: []string{"img/logo.svg"}, Images: []string{"src/a.scss"}}},
+		{name: "unused patterns", rules: assetRules{Include: []string{"*.css", "*.htm"},
+			Exclude: []string{"lib/**"}, Fonts: []string{"fonts/**"}},
+			data: AssetData{CSS: []string{"a.css", "b.css"}},
+			warnings: []string{
+				"m.yaml: assets.include: pattern `*.htm` does not match any file",
+				"m.yaml: assets.fonts: pattern `fonts/**` does not match any file",
+			}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var log strings.Builder
+			b := New(Options{Logger: recordingLogger{testLogger{t}, &log, true}})
+			data, err := b.applyAssetRules("m.yaml", tc.rules, files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(data, tc.data) {
+				t.Errorf("expected %+v, got %+v", tc.data, data)
+			}
+			if actual := strings.Join(tc.warnings, "\n"); strings.TrimSpace(log.String()) != actual {
+				t.Errorf("expected warnings:\n%s\ngot:\n%s", actual, log.String())
+			}
+		})
+	}
+}
+
+func TestApplyAssetRulesErrors(t *testing.T) {
+	b := New(Options{Logger: testLogger{t}})
+	_, err := b.applyAssetRules("m.yaml", assetRules{CSS: []string{"*.css"},
+		Images: []string{"a.*"}, JS: []string{"a.*"}}, []string{"a.css", "b.css"})
+	expected := "m.yaml: assets: web/assets/a.css matches the categories css and js and images"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error `%s`, got %v", expected, err)
+	}
+}
@@ -0,0 +1,459 @@
+package qsbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// apiAssetsDir returns the directory containing the web assets of the
+// QuestScreen api module.
+func (b *Builder) apiAssetsDir() string {
+	apiPath := b.runAndCheck(b.goModCmd("list", "-f", "{{.Dir}}", "-m",
+		"github.com/QuestScreen/api"), func(err error, stderr string) {
+		b.logError("failed to get path to api resources:")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	})
+	return filepath.Join(apiPath, "web", "assets")
+}
+
+// assetsInputs returns the inputs of packAssets. Since packAssets copies all
+// assets into assets/ and packages them there, the directory is an output; the
+// files the web UI phase writes there are thus checked as well.
+func (b *Builder) assetsInputs() phaseInputs {
+	in := phaseInputs{params: []string{b.opts.Web, strconv.FormatBool(b.opts.Debug),
+		strconv.FormatBool(b.opts.DevAssets), strconv.FormatBool(b.opts.Optimize),
+		strconv.FormatBool(b.opts.Fingerprint), strconv.Itoa(int(b.opts.AssetCollisions)),
+		strings.Join(b.opts.AssetOrder, ",")},
+		paths: []string{"go.mod", "go.sum", b.apiAssetsDir(),
+			filepath.Join("web", "assets"), "plugins"},
+		generated: []string{"assets"}}
+	if b.opts.Debug {
+		in.paths = append(in.paths, "web")
+	}
+	for _, p := range b.externalPlugins {
+		in.paths = append(in.paths, p.dir)
+	}
+	return in
+}
+
+var assetsTmpl = template.Must(template.New("assets").Parse(`package assets
+
+// Code generated by qs-build. DO NOT EDIT.
+
+import (
+	{{- if not .Dir}}
+	"embed"
+	{{- end}}
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+{{if .Dir}}
+// FS contains all assets. They are loaded from the directory they have been
+// assembled in at runtime.
+var FS = os.DirFS({{printf "%q" .Dir}})
+{{- else}}
+{{range .Files}}
+//go:embed {{printf "%q" .}}
+{{- end}}
+var files embed.FS
+
+// FS contains all assets.
+var FS fs.FS = files
+{{- end}}
+
+var names = []string{
+	{{- range .Names}}
+	{{printf "%q" .}},
+	{{- end}}
+}
+
+// encodings maps asset names to the content encodings in which a
+// pre-compressed variant of the asset exists, in order of preference.
+var encodings = map[string][]string{
+	{{- range $name, $encs := .Encodings}}
+	{{printf "%q" $name}}: { {{- range $i, $e := $encs}}{{if $i}}, {{end}}{{printf "%q" $e}}{{end -}} },
+	{{- end}}
+}
+
+var encodingExts = map[string]string{
+	{{- range .EncodingExts}}
+	{{printf "%q" .Name}}: {{printf "%q" .Ext}},
+	{{- end}}
+}
+
+// manifest maps logical asset paths to the paths of fingerprinted assets.
+var manifest = map[string]string{
+	{{- range $name, $hashed := .Manifest}}
+	{{printf "%q" $name}}: {{printf "%q" $hashed}},
+	{{- end}}
+}
+
+var fingerprinted = make(map[string]struct{}, len(manifest))
+
+func init() {
+	for _, hashed := range manifest {
+		fingerprinted[hashed] = struct{}{}
+	}
+}
+
+func canonicalName(name string) string {
+	return strings.Trim(strings.Replace(name, "\\", "/", -1), "/")
+}
+
+// Path returns the path the asset with the given logical path is stored at.
+// The path of a fingerprinted asset contains a hash of its content; all other
+// assets are stored at their logical path.
+//
+// All functions that take an asset name accept both the logical path and the
+// path returned by Path.
+func Path(name string) string {
+	name = canonicalName(name)
+	if hashed, ok := manifest[name]; ok {
+		return hashed
+	}
+	return name
+}
+
+// Fingerprinted checks whether path is the path of a fingerprinted asset. Since
+// the content at such a path never changes, it may be cached indefinitely.
+func Fingerprinted(path string) bool {
+	_, ok := fingerprinted[canonicalName(path)]
+	return ok
+}
+
+// Asset loads and returns the asset with the given name.
+func Asset(name string) ([]byte, error) {
+	return fs.ReadFile(FS, Path(name))
+}
+
+// MustAsset is like Asset but panics when Asset would return an error.
+func MustAsset(name string) []byte {
+	content, err := Asset(name)
+	if err != nil {
+		panic("asset: Asset(" + name + "): " + err.Error())
+	}
+	return content
+}
+
+// AssetInfo returns the file info of the asset with the given name.
+func AssetInfo(name string) (os.FileInfo, error) {
+	return fs.Stat(FS, Path(name))
+}
+
+// Encodings returns the content encodings in which the asset with the given
+// name is available pre-compressed, in order of preference. A server should
+// send the content of the first encoding the client accepts, as loaded by
+// AssetEncoded, with a matching Content-Encoding header.
+func Encodings(name string) []string {
+	return encodings[Path(name)]
+}
+
+// AssetEncoded loads the asset with the given name in the given content
+// encoding, which must be one of those returned by Encodings.
+func AssetEncoded(name, encoding string) ([]byte, error) {
+	name = Path(name)
+	for _, e := range encodings[name] {
+		if e == encoding {
+			return fs.ReadFile(FS, name+encodingExts[e])
+		}
+	}
+	return nil, fmt.Errorf("asset %s is not available in encoding %s", name, encoding)
+}
+
+// AssetNames returns the names of all assets as returned by Path.
+// Pre-compressed variants are not included.
+func AssetNames() []string {
+	return append([]string(nil), names...)
+}
+
+// AssetDir returns the names of the files and directories directly inside the
+// directory with the given name. The empty name denotes the root.
+func AssetDir(name string) ([]string, error) {
+	dir := canonicalName(name)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := fs.ReadDir(FS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading directory %s: %w", name, err)
+	}
+	children := make([]string, len(entries))
+	for i, entry := range entries {
+		children[i] = entry.Name()
+	}
+	return children, nil
+}
+
+// RestoreAsset writes the asset with the given name to the same path below
+// dir, creating directories as needed.
+func RestoreAsset(dir, name string) error {
+	content, err := Asset(name)
+	if err != nil {
+		return err
+	}
+	info, err := AssetInfo(name)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(dir, filepath.FromSlash(canonicalName(name)))
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// embedded assets are read-only, which the restored files need not be.
+	if err = os.WriteFile(target, content, info.Mode().Perm()|0200); err != nil {
+		return err
+	}
+	if modTime := info.ModTime(); !modTime.IsZero() {
+		return os.Chtimes(target, modTime, modTime)
+	}
+	return nil
+}
+
+// RestoreAssets writes the asset with the given name, or all assets below it
+// if it is a directory, to the same paths below dir.
+func RestoreAssets(dir, name string) error {
+	children, err := AssetDir(name)
+	if err != nil {
+		// not a directory
+		return RestoreAsset(dir, name)
+	}
+	for _, child := range children {
+		if err = RestoreAssets(dir, path.Join(canonicalName(name), child)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+`))
+
+// writeAssetsPackage generates assets/assets.go, which embeds all files in
+// assets/. With Options.DevAssets, the generated code loads the files from
+// assets/ at runtime instead of embedding them. encodings lists the
+// pre-compressed variants written by optimizeAssets, manifest the fingerprinted
+// assets written by fingerprintAssets.
+func (b *Builder) writeAssetsPackage(includeSourceMap bool, encodings map[string][]string,
+	manifest map[string]string) {
+	type encodingExt struct{ Name, Ext string }
+	data := struct {
+		Dir          string
+		Files, Names []string
+		Encodings    map[string][]string
+		EncodingExts []encodingExt
+		Manifest     map[string]string
+	}{Encodings: make(map[string][]string), Manifest: manifest}
+	if b.opts.DevAssets {
+		data.Dir = b.path("assets")
+	}
+	for _, enc := range assetEncodings {
+		data.EncodingExts = append(data.EncodingExts, encodingExt{enc.name, enc.ext})
+	}
+	// variants maps the names of pre-compressed variants to their asset.
+	variants := make(map[string]string)
+	for name, encs := range encodings {
+		for _, enc := range assetEncodings {
+			for _, e := range encs {
+				if e == enc.name {
+					variants[name+enc.ext] = name
+				}
+			}
+		}
+	}
+	included := func(name string) bool {
+		if asset, ok := variants[name]; ok {
+			name = asset
+		}
+		// the originals of fingerprinted assets that have been copied.
+		if _, ok := manifest[name]; ok {
+			return false
+		}
+		return includeSourceMap || name != "main.js.map"
+	}
+
+	for _, name := range b.listAssets() {
+		switch {
+		case !included(name):
+		case strings.ContainsAny(name, "*?[\\\""):
+			b.logWarning("skipping asset with unsupported name: %s", name)
+		default:
+			data.Files = append(data.Files, name)
+			if _, ok := variants[name]; !ok {
+				data.Names = append(data.Names, name)
+				if encs, ok := encodings[name]; ok {
+					data.Encodings[name] = encs
+				}
+			}
+		}
+	}
+
+	var writer strings.Builder
+	b.must(assetsTmpl.Execute(&writer, data), "failed to generate assets package:")
+	b.writeFormatted(writer.String(), b.path("assets", "assets.go"))
+}
+
+// listAssets returns the names of all files in assets/ except the generated
+// assets package, relative to assets/ and with forward slashes.
+func (b *Builder) listAssets() []string {
+	assetsDir := b.path("assets")
+	var names []string
+	b.must(filepath.Walk(assetsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(assetsDir, path)
+		if err == nil && name != "assets.go" {
+			names = append(names, filepath.ToSlash(name))
+		}
+		return err
+	}), "failed to list assets:")
+	return names
+}
+
+func (b *Builder) packAssets() {
+	assetsDir := b.path("assets")
+	required := make(map[string]struct{})
+	required["index.html"] = struct{}{}
+	if b.wasm {
+		required["main.wasm"] = struct{}{}
+		required["wasm_exec.js"] = struct{}{}
+	} else {
+		required["main.js"] = struct{}{}
+		required["main.js.map"] = struct{}{}
+	}
+	// webUIOutputs are the files required in assets/, which are written by the
+	// web UI phase. They may be minified, but must otherwise be kept.
+	webUIOutputs := make(map[string]struct{}, len(required))
+	for name := range required {
+		webUIOutputs[name] = struct{}{}
+	}
+
+	if _, err := os.Stat(assetsDir); err != nil {
+		if os.IsNotExist(err) {
+			b.logError("`assets` directory not existing")
+			b.logError("please execute command `webui` before `assets`")
+			b.fail()
+		}
+		b.logError("failed to query for assets directory:")
+		b.logError(err.Error())
+		b.fail()
+	} else {
+		b.logInfo("cleaning up")
+		files, err := ioutil.ReadDir(assetsDir)
+		if err != nil {
+			b.logError("failed to read `assets` directory:")
+			b.logError(err.Error())
+			b.fail()
+		}
+		for _, file := range files {
+			if _, ok := required[file.Name()]; ok {
+				delete(required, file.Name())
+			} else {
+				if err = os.RemoveAll(filepath.Join(assetsDir, file.Name())); err != nil {
+					b.logError("failed to remove assets/" + file.Name() + ":")
+					b.logError(err.Error())
+					b.fail()
+				}
+			}
+		}
+		for key := range required {
+			b.logError("The file assets/%s is missing", key)
+			b.logError("Please run command `webui` before `assets`")
+			b.fail()
+		}
+	}
+
+	var plugins Data
+	pluginYamlFile, err := ioutil.ReadFile(b.path("plugins", "plugins.yaml"))
+	b.mustCond(err == nil, "missing file: plugins/plugins.yaml", "please run command `plugins` before `assets`.")
+	b.must(yaml.Unmarshal(pluginYamlFile, &plugins), "failed to read plugins/plugins.yaml:")
+
+	b.logInfo("collecting assets from api resources, `web/assets` and plugins")
+	files := b.resolveAssetCollisions(b.collectAssets(plugins, webUIOutputs))
+	b.logInfo("copying assets into `assets`")
+	for name, file := range files {
+		if file.path == "" {
+			continue
+		}
+		target := filepath.Join(assetsDir, filepath.FromSlash(name))
+		b.must(os.MkdirAll(filepath.Dir(target), 0755), "failed to create directory for assets/"+name+":")
+		b.must(CopyFile(file.path, target), "failed to copy "+file.path+" to assets/"+name+":")
+	}
+
+	// assets are minified before they are fingerprinted so that the hash is
+	// calculated from the final content.
+	if b.opts.Optimize {
+		b.logInfo("minifying assets")
+		b.minifyAssets()
+	}
+	var manifest map[string]string
+	if b.opts.Fingerprint {
+		b.logInfo("fingerprinting assets")
+		manifest = b.fingerprintAssets(webUIOutputs)
+	}
+	var encodings map[string][]string
+	if b.opts.Optimize {
+		b.logInfo("pre-compressing assets")
+		encodings = b.compressAssets(manifest)
+	}
+
+	if b.opts.DevAssets {
+		b.logInfo("generating assets/assets.go for loading assets from disk")
+	} else {
+		b.logInfo("packaging assets into assets/assets.go")
+	}
+	b.writeAssetsPackage(false, encodings, manifest)
+
+	if b.opts.Debug {
+		b.logInfo("bunding Go source files for JavaScript debugging")
+		b.runAndCheck(b.goModCmd("mod", "vendor"),
+			func(err error, stderr string) {
+				b.logError("failed to execute `go mod vendor`:")
+				b.logError(err.Error())
+				b.writeErrorLines(stderr)
+			})
+		vendorDir := b.path("vendor")
+		items, err := ioutil.ReadDir(vendorDir)
+		if err != nil {
+			b.logError("failed to read generated `vendor` directory:")
+			b.logError(err.Error())
+			b.logError("after solving the problem, remove `vendor` before trying again")
+			b.fail()
+		}
+		for _, item := range items {
+			if item.IsDir() {
+				if err = os.Rename(filepath.Join(vendorDir, item.Name()),
+					filepath.Join(assetsDir, item.Name())); err != nil {
+					b.logError("failed to rename `vendor/" + item.Name() + "` to assets/" +
+						item.Name() + ":")
+					b.logError(err.Error())
+					b.logError("after solving the problem, remove `vendor` before trying again")
+					b.fail()
+				}
+			}
+		}
+		if err = os.RemoveAll(vendorDir); err != nil {
+			b.logError("failed to remove `vendor` directory:")
+			b.logError(err.Error())
+			b.logError("after solving the problem, remove `vendor` before trying again")
+			b.fail()
+		}
+		sourcesDir := filepath.Join(assetsDir, "github.com", "QuestScreen", "QuestScreen")
+		b.must(os.MkdirAll(sourcesDir, 0755),
+			"failed to create directory assets/github.com/QuestScreen/QuestScreen:")
+		b.must(CopyDir(b.path("web"), filepath.Join(sourcesDir, "web")),
+			"failed to copy Go sources into assets:")
+		os.RemoveAll(filepath.Join(sourcesDir, "web", "assets"))
+		b.logInfo("re-packaging to include source files")
+		b.writeAssetsPackage(true, encodings, manifest)
+	}
+}
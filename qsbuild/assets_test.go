@@ -0,0 +1,85 @@
+package qsbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restoreProgram restores all assets of the generated assets package into the
+// directory given as argument and prints the path of a fingerprinted asset.
+const restoreProgram = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.com/qs/assets"
+)
+
+func main() {
+	if err := assets.RestoreAssets(os.Args[1], ""); err != nil {
+		panic(err)
+	}
+	if err := assets.RestoreAsset(os.Args[1], "css/style.css"); err != nil {
+		panic(err)
+	}
+	fmt.Print(assets.Path("css/style.css"))
+}
+`
+
+func TestAssetsPackage(t *testing.T) {
+	files := map[string]string{
+		"assets/index.html":                 "<html></html>",
+		"assets/css/style.0123456789ab.css": "body {}",
+		"assets/main.js.map":                "{}",
+	}
+	for _, devAssets := range []bool{false, true} {
+		root := t.TempDir()
+		writeTestFiles(t, root, files)
+		writeTestFiles(t, root, map[string]string{
+			"go.mod":          "module example.com/qs\n\ngo 1.22.0\n",
+			"restore/main.go": restoreProgram,
+		})
+		b := New(Options{DevAssets: devAssets, Logger: testLogger{t}})
+		b.root = root
+		if err := b.run("Assets", func() {
+			b.writeAssetsPackage(false, nil, map[string]string{"css/style.css": "css/style.0123456789ab.css"})
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		out := t.TempDir()
+		cmd := exec.Command("go", "run", "./restore", out)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("dev assets: %v: %v\n%s", devAssets, err, output)
+		}
+		if string(output) != "css/style.0123456789ab.css" {
+			t.Errorf("dev assets: %v: unexpected path %s", devAssets, output)
+		}
+		for name, content := range files {
+			name = strings.TrimPrefix(name, "assets/")
+			restored, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+			switch {
+			case name == "main.js.map" && !devAssets:
+				// the source map is only packaged for debugging.
+				if !os.IsNotExist(err) {
+					t.Errorf("dev assets: %v: %s has been restored", devAssets, name)
+				}
+			case err != nil:
+				t.Errorf("dev assets: %v: %v", devAssets, err)
+			case string(restored) != content:
+				t.Errorf("dev assets: %v: unexpected content of %s: %s", devAssets, name, restored)
+			}
+		}
+		// the asset restored by its logical path.
+		if _, err := os.Stat(filepath.Join(out, "css", "style.css")); err != nil {
+			t.Errorf("dev assets: %v: %v", devAssets, err)
+		}
+	}
+}
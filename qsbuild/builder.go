@@ -0,0 +1,424 @@
+// Package qsbuild builds QuestScreen from source: it discovers plugins,
+// generates the code for loading them, builds the web UI, packages the assets
+// and compiles the main app.
+//
+// A Builder operates on a QuestScreen source directory. Its phase methods
+// correspond to the commands of the qs-build tool; they report their progress
+// to a Logger and return a *PhaseError on failure. Phases whose inputs have not
+// changed since their last successful run are skipped; the fingerprints of the
+// inputs are kept in .qs-build/ inside the source directory.
+package qsbuild
+
+import (
+	"bytes"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Options configures a Builder.
+type Options struct {
+	// Root is the QuestScreen source directory. Defaults to the working
+	// directory.
+	Root string
+	// Verbose enables verbose debug information.
+	Verbose bool
+	// Debug builds an executable for debugging that includes the JS source map
+	// and Go sources. Implies Web "gopherjs".
+	Debug bool
+	// Web is the backend used for the web UI, either "wasm" (default) or
+	// "gopherjs".
+	Web string
+	// DevAssets generates an assets package that loads the files from assets/ at
+	// runtime instead of embedding them, so that changed assets do not require
+	// compiling the main app again. Not allowed for releases.
+	DevAssets bool
+	// Optimize minifies CSS, JavaScript and SVG assets and pre-compresses large
+	// assets with brotli and gzip before packaging them.
+	Optimize bool
+	// Fingerprint renames assets to contain a hash of their content, so that the
+	// app can serve them with long-lived cache headers. References in index.html
+	// and CSS files are rewritten accordingly.
+	Fingerprint bool
+	// AssetCollisions determines how an asset path provided by more than one
+	// of the api module, web/assets and the plugins is handled.
+	AssetCollisions CollisionPolicy
+	// AssetOrder lists the sources of assets from lowest to highest precedence,
+	// which decides the file used for colliding asset paths. It must contain
+	// "api", "web" and "plugins", and may contain plugin IDs to rank single
+	// plugins apart from the others. Defaults to api, web, plugins.
+	AssetOrder []string
+	// PluginFile is the path to a file that contains the import paths of all
+	// external plugins. Defaults to plugins/plugins.txt if that file exists.
+	PluginFile string
+	// UpdateLock resolves external plugins anew instead of loading their
+	// versions from plugins.lock, and writes the new versions to plugins.lock.
+	UpdateLock bool
+	// Force runs all phases even if their inputs have not changed since their
+	// last successful run.
+	Force bool
+	// Jobs is the number of goroutines that inspect module configurations
+	// statically and that minify and compress assets. Loading the packages and
+	// the inspector program for configurations that cannot be inspected
+	// statically are not parallelized. Defaults to the number of CPUs.
+	Jobs int
+	// Logger receives all messages. Defaults to NewConsoleLogger().
+	Logger Logger
+}
+
+// Builder builds a QuestScreen source directory. It is created with New and
+// must be closed with Close. Its phases must not be run concurrently.
+type Builder struct {
+	opts Options
+	log  Logger
+	root string
+	wasm bool
+
+	goCmd, goBin, apiVersion   string
+	goModPath, goSumPath       string
+	goModContent, goSumContent []byte
+
+	// sessionModDir contains copies of go.mod and go.sum. All go commands that
+	// operate on the QuestScreen module use these copies via -modfile, so that
+	// resolving external plugins never modifies the checked-in module files.
+	sessionModDir, sessionModPath string
+	// externalPlugins are the plugins given in the plugins file.
+	externalPlugins []pluginDescr
+	// pendingLock is the plugins.lock created by Init, which is written at
+	// pendingLockPath by the Plugins phase so that Init does not write any files.
+	pendingLock     *pluginsLock
+	pendingLockPath string
+
+	cache    buildCache
+	toolHash string
+
+	cleanup     cleanupRegistry
+	initialized bool
+	initErr     error
+
+	errMutex sync.Mutex
+	// firstError is the first error message logged in the current phase.
+	firstError string
+}
+
+// PhaseError is returned by a phase that failed. The details of the failure
+// have been reported to the Logger.
+type PhaseError struct {
+	Phase string
+	// Msg is the first error message that has been reported.
+	Msg string
+}
+
+func (e *PhaseError) Error() string {
+	if e.Msg == "" {
+		return e.Phase + " failed"
+	}
+	return e.Phase + " failed: " + e.Msg
+}
+
+// abort is the panic value that stops the current phase.
+type abort struct{}
+
+// fail stops the current phase. The reason must have been logged.
+func (b *Builder) fail() {
+	panic(abort{})
+}
+
+// phase runs f as the phase with the given name and turns a failure into a
+// *PhaseError. The Builder is initialized first if necessary.
+func (b *Builder) phase(name string, f func()) (err error) {
+	if err := b.Init(); err != nil {
+		return err
+	}
+	return b.run(name, f)
+}
+
+func (b *Builder) run(name string, f func()) (err error) {
+	b.logPhase(name)
+	b.errMutex.Lock()
+	b.firstError = ""
+	b.errMutex.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(abort); !ok {
+				panic(r)
+			}
+			b.errMutex.Lock()
+			err = &PhaseError{Phase: name, Msg: b.firstError}
+			b.errMutex.Unlock()
+		}
+	}()
+	f()
+	return nil
+}
+
+// New creates a Builder for the QuestScreen source directory given in opts.
+// It does not access the source directory; this is done by Init.
+func New(opts Options) *Builder {
+	b := &Builder{opts: opts, log: opts.Logger}
+	if b.log == nil {
+		b.log = NewConsoleLogger()
+	}
+	return b
+}
+
+// Init checks the options and the QuestScreen module, locates the Go
+// toolchain and loads the external plugins from the plugins file.
+// It is called by the first phase if it has not been called before; calling it
+// again returns the result of the first call.
+func (b *Builder) Init() error {
+	if !b.initialized {
+		b.initialized = true
+		b.initErr = b.run("Init", b.init)
+	}
+	return b.initErr
+}
+
+func (b *Builder) init() {
+	if b.opts.Debug {
+		if b.opts.Web != "" && b.opts.Web != "gopherjs" {
+			b.logError("debug builds do not allow web UI backend '%s'", b.opts.Web)
+			b.fail()
+		}
+		b.opts.Web = "gopherjs"
+	}
+	switch b.opts.Web {
+	case "", "wasm":
+		b.opts.Web = "wasm"
+		b.wasm = true
+	case "gopherjs":
+		b.wasm = false
+	default:
+		b.logError("unknown web backend: '%s'", b.opts.Web)
+		b.fail()
+	}
+	switch b.opts.AssetCollisions {
+	case CollisionWarn, CollisionError, CollisionOverride:
+	default:
+		b.logError("unknown asset collision policy: %d", b.opts.AssetCollisions)
+		b.fail()
+	}
+	b.checkAssetOrder()
+	if b.opts.Jobs <= 0 {
+		b.opts.Jobs = runtime.NumCPU()
+	}
+
+	var err error
+	if b.opts.Root == "" {
+		b.root, err = os.Getwd()
+		b.must(err, "failed to get current directory:")
+	} else {
+		b.root, err = filepath.Abs(b.opts.Root)
+		b.must(err)
+	}
+
+	b.goCmd = filepath.Join(build.Default.GOROOT, "bin", "go")
+	b.goBin = b.runAndCheck(b.command(b.goCmd, "env", "GOBIN"), func(err error, stderr string) {
+		b.logError("failed to get GOBIN:")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	})
+	if b.goBin == "" {
+		b.goBin = filepath.Join(build.Default.GOPATH, "bin")
+	}
+
+	b.findQuestScreenModule()
+	b.loadCache()
+
+	if b.opts.PluginFile == "" {
+		info, err := os.Stat(b.path("plugins", "plugins.txt"))
+		if err == nil && !info.IsDir() {
+			b.opts.PluginFile = b.path("plugins", "plugins.txt")
+		}
+	} else {
+		b.opts.PluginFile, err = filepath.Abs(b.opts.PluginFile)
+		b.must(err)
+	}
+	if b.opts.PluginFile != "" {
+		b.loadPluginsFile(b.opts.PluginFile)
+	} else if b.opts.UpdateLock {
+		b.logWarning("no plugins file given, nothing to update")
+	}
+}
+
+// Close stops all child processes, removes all temporary files and restores
+// go.mod and go.sum if a tool that ignores the session's copies has modified
+// them while running. Only the first call has any effect.
+func (b *Builder) Close() {
+	b.runCleanup()
+}
+
+// Interrupt is like Close, but may be called while a phase is running, which
+// then fails. It is meant to be called from signal handlers.
+func (b *Builder) Interrupt() {
+	b.cleanup.Lock()
+	b.cleanup.interrupted = true
+	b.cleanup.Unlock()
+	b.runCleanup()
+}
+
+// Root returns the absolute path of the QuestScreen source directory.
+func (b *Builder) Root() string {
+	return b.root
+}
+
+// path returns the absolute path of the given path elements inside the
+// QuestScreen source directory.
+func (b *Builder) path(elem ...string) string {
+	return filepath.Join(append([]string{b.root}, elem...)...)
+}
+
+// findQuestScreenModule checks if the root contains the QuestScreen module.
+func (b *Builder) findQuestScreenModule() {
+	var err error
+	b.goModPath = b.path("go.mod")
+	_, err = os.Stat(b.goModPath)
+	b.must(err, "failed to find go.mod:")
+	b.goModContent, err = ioutil.ReadFile(b.goModPath)
+	if err == nil {
+		b.goSumPath = b.path("go.sum")
+		b.goSumContent, err = ioutil.ReadFile(b.goSumPath)
+		b.must(err)
+
+		var mod *modfile.File
+		if mod, err = modfile.Parse("go.mod", b.goModContent, nil); err != nil {
+			b.logWarning("unable to parse go.mod: %v", err.Error())
+		} else {
+			if mod != nil && mod.Module != nil && mod.Module.Mod.Path == "github.com/QuestScreen/QuestScreen" {
+				for _, r := range mod.Require {
+					if r.Mod.Path == "github.com/QuestScreen/api" {
+						b.apiVersion = r.Mod.Version
+						b.createSessionModFile()
+						return
+					}
+				}
+				b.logError("failed to find reference to github.com/QuestScreen/api in go.mod")
+				b.fail()
+			}
+		}
+	} else {
+		if !os.IsNotExist(err) {
+			b.logWarning("while reading go.mod: %v", err.Error())
+		}
+	}
+
+	b.logError(b.root + " is not QuestScreen source directory!")
+	b.fail()
+}
+
+func (b *Builder) createSessionModFile() {
+	var err error
+	b.sessionModDir, err = ioutil.TempDir("", "qs-build-mod")
+	b.must(err, "failed to create temporary directory:")
+	b.sessionModPath = filepath.Join(b.sessionModDir, "go.mod")
+	dir := b.sessionModDir
+	b.onCleanup(func() {
+		os.RemoveAll(dir)
+	})
+	b.must(ioutil.WriteFile(b.sessionModPath, b.goModContent, 0644),
+		"failed to write temporary go.mod:")
+	b.must(ioutil.WriteFile(filepath.Join(b.sessionModDir, "go.sum"), b.goSumContent, 0644),
+		"failed to write temporary go.sum:")
+}
+
+// protectModFiles snapshots go.mod and go.sum before running tools that may
+// write to them because they do not support -modfile, like gopherjs which is
+// run with go1.12. The returned function restores the snapshots if the files
+// have been modified; it is also called if the Builder is closed before.
+func (b *Builder) protectModFiles() func() {
+	var restore []func()
+	for _, path := range []string{b.goModPath, b.goSumPath} {
+		path := path
+		stat, err := os.Stat(path)
+		b.must(err, "failed to query "+path+":")
+		content, err := ioutil.ReadFile(path)
+		b.must(err, "failed to read "+path+":")
+		restore = append(restore, func() { b.restoreModFile(path, content, stat.Mode()) })
+	}
+	return b.onCleanup(func() {
+		for _, f := range restore {
+			f()
+		}
+	})
+}
+
+// restoreModFile writes the original content back to a module file if it has
+// been modified.
+func (b *Builder) restoreModFile(path string, content []byte, mode os.FileMode) {
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		return
+	}
+	b.logWarning("restoring modified %s", path)
+	if err = ioutil.WriteFile(path, content, mode); err != nil {
+		b.logError("failed to restore %s: %s", path, err.Error())
+	}
+}
+
+// Deps ensures that all dependencies required for building QuestScreen are
+// available.
+func (b *Builder) Deps() error {
+	return b.cachedPhase("Build Dependencies", b.depsInputs, b.ensureDepsAvailable)
+}
+
+// Validate checks the plugin manifests and template references without
+// writing any files. All problems of all plugins are reported.
+func (b *Builder) Validate() error {
+	return b.phase("Validate", b.validatePlugins)
+}
+
+// DiscoverPlugins discovers all plugins, checks them like Validate and returns
+// them in load order.
+func (b *Builder) DiscoverPlugins() (Data, error) {
+	var plugins Data
+	err := b.phase("Discover", func() {
+		plugins = b.discoverAndProcess()
+	})
+	return plugins, err
+}
+
+// Plugins discovers all plugins and writes the code for loading them in the
+// web UI and the main app.
+func (b *Builder) Plugins() error {
+	return b.cachedPhase("Plugins", b.pluginsInputs, b.writePluginLoaders)
+}
+
+// WebUI compiles the web UI into assets/.
+func (b *Builder) WebUI() error {
+	return b.cachedPhase("Web UI", b.webUIInputs, b.buildWebUI)
+}
+
+// Assets packages all web files in assets/ into assets/assets.go.
+func (b *Builder) Assets() error {
+	return b.cachedPhase("Assets", b.assetsInputs, b.packAssets)
+}
+
+// Compile compiles the main app. Unlike the other phases, it always runs since
+// the go command caches its results anyway.
+func (b *Builder) Compile() error {
+	return b.phase("Compile", b.compileQuestscreen)
+}
+
+// Build runs all phases except Validate in order and stops at the first one
+// that fails.
+func (b *Builder) Build() error {
+	for _, phase := range []func() error{b.Deps, b.Plugins, b.WebUI, b.Assets, b.Compile} {
+		if err := phase(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release creates a release of the given kind from a clean git working
+// directory.
+func (b *Builder) Release(kind ReleaseKind) error {
+	return b.phase("Release", func() {
+		b.release(kind)
+	})
+}
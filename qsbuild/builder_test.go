@@ -0,0 +1,284 @@
+package qsbuild
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testLogger writes all messages of a Builder to the test log.
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Phase(name string)  { l.t.Log("[phase] " + name) }
+func (l testLogger) Info(msg string)    { l.t.Log("[info] " + msg) }
+func (l testLogger) Verbose(msg string) { l.t.Log("[verbose] " + msg) }
+func (l testLogger) Warning(msg string) { l.t.Log("[warning] " + msg) }
+func (l testLogger) Error(msg string)   { l.t.Log("[error] " + msg) }
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// questScreenFixture contains a minimal QuestScreen source directory with two
+// plugins, where the second plugin references a scene template of the first.
+var questScreenFixture = map[string]string{
+	"go.mod": `module github.com/QuestScreen/QuestScreen
+
+go 1.22.0
+
+require github.com/QuestScreen/api v0.3.1-0.20210428171433-ca9199676fb2
+`,
+	"go.sum": "",
+	"plugins/base/questscreen-plugin.yaml": `name: Base
+modules: []
+templates:
+  scenes:
+    main: {name: Main}
+`,
+	"plugins/extra/questscreen-plugin.yaml": `name: Extra
+modules: []
+templates:
+  groups:
+    - name: Group
+      scenes:
+        - {name: Scene, template: base.main}
+`,
+}
+
+// newFixture writes questScreenFixture, modified by changes, to a temporary
+// directory and returns a Builder for it. A change with empty content removes
+// the file.
+func newFixture(t *testing.T, changes map[string]string) *Builder {
+	t.Helper()
+	files := make(map[string]string, len(questScreenFixture))
+	for name, content := range questScreenFixture {
+		files[name] = content
+	}
+	for name, content := range changes {
+		if content == "" {
+			delete(files, name)
+		} else {
+			files[name] = content
+		}
+	}
+	root := t.TempDir()
+	writeTestFiles(t, root, files)
+	b := New(Options{Root: root, Logger: testLogger{t}})
+	t.Cleanup(b.Close)
+	return b
+}
+
+// checkPhaseError fails if err is not a *PhaseError of the given phase whose
+// message contains msg.
+func checkPhaseError(t *testing.T, err error, phase, msg string) {
+	t.Helper()
+	var pe *PhaseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PhaseError of %s, got %v", phase, err)
+	}
+	if pe.Phase != phase || !strings.Contains(pe.Msg, msg) {
+		t.Errorf("expected %s to fail with `%s`, got: %v", phase, msg, err)
+	}
+}
+
+func TestValidateFixture(t *testing.T) {
+	b := newFixture(t, nil)
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// validation must not write into the source directory.
+	var written []string
+	filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(b.root, path)
+			if _, ok := questScreenFixture[filepath.ToSlash(rel)]; !ok {
+				written = append(written, rel)
+			}
+		}
+		return err
+	})
+	if _, err := os.Stat(b.path("assets")); err == nil {
+		written = append(written, "assets")
+	}
+	if len(written) > 0 {
+		t.Errorf("Init and Validate wrote %v", written)
+	}
+	plugins, err := b.DiscoverPlugins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 2 || plugins[0].ID != "base" || plugins[1].ID != "extra" {
+		t.Fatalf("unexpected plugins: %+v", plugins)
+	}
+	ref := plugins[1].Templates.Groups[0].Scenes[0]
+	if ref.PluginIndex != 0 || ref.TmplIndex != 0 {
+		t.Errorf("scene reference resolved to plugin %d, template %d",
+			ref.PluginIndex, ref.TmplIndex)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		changes map[string]string
+		msg     string
+	}{
+		{name: "missing go.mod", changes: map[string]string{"go.mod": ""},
+			msg: "failed to find go.mod"},
+		{name: "not QuestScreen", changes: map[string]string{"go.mod": "module example.com/other\n"},
+			msg: "is not QuestScreen source directory"},
+		{name: "missing api", changes: map[string]string{
+			"go.mod": "module github.com/QuestScreen/QuestScreen\n"},
+			msg: "failed to find reference to github.com/QuestScreen/api"},
+		{name: "unknown web backend", opts: Options{Web: "flash"},
+			msg: "unknown web backend: 'flash'"},
+		{name: "debug with wasm", opts: Options{Debug: true, Web: "wasm"},
+			msg: "debug builds do not allow web UI backend 'wasm'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newFixture(t, tc.changes)
+			b.opts.Web, b.opts.Debug = tc.opts.Web, tc.opts.Debug
+			checkPhaseError(t, b.Init(), "Init", tc.msg)
+			// phases report the error of Init.
+			checkPhaseError(t, b.Validate(), "Init", tc.msg)
+		})
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		changes map[string]string
+		logged  []string
+	}{
+		{name: "invalid manifest", changes: map[string]string{
+			"plugins/base/questscreen-plugin.yaml": "name: Base\nmodules: {}\n"},
+			logged: []string{"questscreen-plugin.yaml:2:10: "}},
+		{name: "unknown scene template", changes: map[string]string{
+			"plugins/base/questscreen-plugin.yaml": "name: Base\nmodules: []\n"},
+			logged: []string{"base.main"}},
+		// scene references are checked although another plugin is broken.
+		{name: "all problems", changes: map[string]string{
+			"plugins/base/questscreen-plugin.yaml":   "name: Base\nmodules: []\n",
+			"plugins/broken/questscreen-plugin.yaml": "modules: []\n"},
+			logged: []string{"missing required key `name`", "`base.main`: unknown scene: main"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newFixture(t, tc.changes)
+			var log strings.Builder
+			b.log = recordingLogger{testLogger{t}, &log, false}
+			checkPhaseError(t, b.Validate(), "Validate", "")
+			for _, msg := range tc.logged {
+				if !strings.Contains(log.String(), msg) {
+					t.Errorf("expected an error containing `%s`, got:\n%s", msg, log.String())
+				}
+			}
+		})
+	}
+}
+
+// recordingLogger additionally records all error messages, or all warnings if
+// warnings is set.
+type recordingLogger struct {
+	testLogger
+	messages *strings.Builder
+	warnings bool
+}
+
+func (l recordingLogger) Warning(msg string) {
+	l.testLogger.Warning(msg)
+	if l.warnings {
+		l.messages.WriteString(msg + "\n")
+	}
+}
+
+func (l recordingLogger) Error(msg string) {
+	l.testLogger.Error(msg)
+	if !l.warnings {
+		l.messages.WriteString(msg + "\n")
+	}
+}
+
+func TestProtectModFiles(t *testing.T) {
+	b := newFixture(t, nil)
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, release := range []func(){nil, b.Close} {
+		var restore func()
+		if err := b.run("Web UI", func() { restore = b.protectModFiles() }); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFiles(t, b.root, map[string]string{
+			"go.mod": "module modified\n", "go.sum": "modified\n"})
+		if release == nil {
+			restore()
+		} else {
+			release()
+		}
+		for _, name := range []string{"go.mod", "go.sum"} {
+			content, err := ioutil.ReadFile(b.path(name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != questScreenFixture[name] {
+				t.Errorf("%s has not been restored: %s", name, content)
+			}
+		}
+	}
+}
+
+func TestGopherjsModule(t *testing.T) {
+	b := newFixture(t, map[string]string{
+		"go.mod": questScreenFixture["go.mod"] +
+			"\nreplace example.com/local => ../local\n",
+		"assets/index.html": "index",
+		".git/HEAD":         "ref: refs/heads/master",
+	})
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	var dir string
+	var remove func()
+	if err := b.run("Web UI", func() { dir, remove = b.gopherjsModule() }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "plugins", "base", "questscreen-plugin.yaml")); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{".git", "assets", cacheDir} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s has been copied", name)
+		}
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(filepath.Dir(b.root), "local")
+	if !strings.Contains(string(content), "example.com/local => "+local) {
+		t.Errorf("relative replace has not been made absolute:\n%s", content)
+	}
+	remove()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("module copy has not been removed")
+	}
+}
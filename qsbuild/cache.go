@@ -0,0 +1,246 @@
+package qsbuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheDir is the directory inside the root that holds the build cache.
+const cacheDir = ".qs-build"
+
+// buildCache records a fingerprint for each phase that has completed
+// successfully. A phase is skipped as long as the fingerprint of its inputs
+// and outputs does not change.
+//
+// The inputs are hashed before a phase runs, so that a source changed while
+// the phase is running is built by the next run. The outputs are hashed after
+// the phase has run, so that a phase runs again when its outputs have been
+// modified or removed; since outputs of earlier phases are inputs of later
+// ones, a phase whose outputs did not change does not cause later phases to run
+// again.
+type buildCache struct {
+	Phases map[string]phaseFingerprint `json:"phases"`
+}
+
+// phaseFingerprint is the fingerprint of a phase's inputs and outputs.
+type phaseFingerprint struct {
+	Inputs  string `json:"inputs"`
+	Outputs string `json:"outputs"`
+}
+
+// phaseInputs describes everything a cached phase depends on.
+type phaseInputs struct {
+	// params are the options that influence the phase's result.
+	params []string
+	// paths are hashed by content; directories are hashed recursively. Paths
+	// may be relative to the root and may contain glob patterns. Missing paths
+	// are part of the fingerprint. Files below paths that carry a generated
+	// code header have been written by a phase and are hashed as outputs.
+	paths []string
+	// exclude contains paths below paths that are not to be hashed.
+	exclude []string
+	// generated are paths written by the phase. They are hashed as outputs
+	// like paths and are excluded from the inputs.
+	generated []string
+	// outputs are files written by the phase that may be modified by later
+	// phases. Only whether they exist is part of the outputs' fingerprint.
+	outputs []string
+}
+
+func (b *Builder) loadCache() {
+	b.cache.Phases = make(map[string]phaseFingerprint)
+	content, err := ioutil.ReadFile(b.path(cacheDir, "cache.json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			b.logWarning("unable to read build cache, rebuilding everything: %s", err.Error())
+		}
+		return
+	}
+	if err = json.Unmarshal(content, &b.cache); err != nil || b.cache.Phases == nil {
+		b.logWarning("build cache is corrupt or outdated, rebuilding everything")
+		b.cache.Phases = make(map[string]phaseFingerprint)
+	}
+}
+
+func (b *Builder) writeCache() {
+	dir := b.path(cacheDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		b.logWarning("unable to create %s: %s", dir, err.Error())
+		return
+	}
+	// keep the cache out of git, which would otherwise prevent releases since
+	// they require a clean working directory.
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		ioutil.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	content, err := json.MarshalIndent(&b.cache, "", "  ")
+	b.must(err)
+	if err = ioutil.WriteFile(filepath.Join(dir, "cache.json"), content, 0644); err != nil {
+		b.logWarning("unable to write build cache: %s", err.Error())
+	}
+}
+
+// cachedPhase runs f as phase like phase does, but skips it if the fingerprint
+// of the inputs returned by inputs is the one recorded for the last successful
+// run.
+func (b *Builder) cachedPhase(name string, inputs func() phaseInputs, f func()) error {
+	return b.phase(name, func() {
+		in := inputs()
+		fp, err := b.fingerprint(in)
+		if err != nil {
+			b.logWarning("unable to fingerprint inputs: %s", err.Error())
+		} else if prev, ok := b.cache.Phases[name]; ok && !b.opts.Force && prev == fp {
+			b.logInfo("inputs unchanged, skipping (use --force to run anyway)")
+			return
+		}
+		// an interrupted or failed run must not leave a valid fingerprint behind.
+		if _, ok := b.cache.Phases[name]; ok {
+			delete(b.cache.Phases, name)
+			b.writeCache()
+		}
+		f()
+		if err != nil {
+			return
+		}
+		if after, err := b.fingerprint(in); err != nil {
+			b.logWarning("unable to fingerprint outputs: %s", err.Error())
+		} else {
+			b.cache.Phases[name] = phaseFingerprint{Inputs: fp.Inputs, Outputs: after.Outputs}
+			b.writeCache()
+		}
+	})
+}
+
+// fingerprint calculates hashes of the given inputs, including the running
+// executable so that updating qs-build invalidates the cache, and of the given
+// outputs.
+func (b *Builder) fingerprint(in phaseInputs) (phaseFingerprint, error) {
+	if b.toolHash == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return phaseFingerprint{}, err
+		}
+		if b.toolHash, err = hashFile(exe); err != nil {
+			return phaseFingerprint{}, err
+		}
+	}
+	inputs, outputs := sha256.New(), sha256.New()
+	fmt.Fprintf(inputs, "tool %s\n", b.toolHash)
+	for _, p := range in.params {
+		fmt.Fprintf(inputs, "param %q\n", p)
+	}
+	exclude := make(map[string]struct{}, len(in.exclude)+len(in.generated))
+	for _, p := range in.exclude {
+		exclude[b.absPath(p)] = struct{}{}
+	}
+	for _, p := range in.generated {
+		exclude[b.absPath(p)] = struct{}{}
+	}
+	hashPaths := func(paths []string, h, generated hash.Hash, exclude map[string]struct{}) error {
+		for _, p := range paths {
+			p = b.absPath(p)
+			matches := []string{p}
+			if strings.ContainsAny(p, "*?[") {
+				var err error
+				if matches, err = filepath.Glob(p); err != nil {
+					return err
+				}
+				fmt.Fprintf(h, "glob %q %d\n", p, len(matches))
+			}
+			for _, m := range matches {
+				if err := hashTree(h, generated, m, exclude); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := hashPaths(in.paths, inputs, outputs, exclude); err != nil {
+		return phaseFingerprint{}, err
+	}
+	if err := hashPaths(in.generated, outputs, outputs, nil); err != nil {
+		return phaseFingerprint{}, err
+	}
+	for _, p := range in.outputs {
+		_, err := os.Stat(b.absPath(p))
+		fmt.Fprintf(outputs, "output %q %t\n", p, err == nil)
+	}
+	return phaseFingerprint{Inputs: hex.EncodeToString(inputs.Sum(nil)),
+		Outputs: hex.EncodeToString(outputs.Sum(nil))}, nil
+}
+
+// absPath resolves path against the root unless it is absolute.
+func (b *Builder) absPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return b.path(path)
+}
+
+// hashTree writes the names and content hashes of root and, if it is a
+// directory, of all files below it to h. Files with a generated code header are
+// written to generated instead.
+func hashTree(h, generated hash.Hash, root string, exclude map[string]struct{}) error {
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "missing %q\n", root)
+			return nil
+		}
+		return err
+	}
+	return filepath.Walk(resolved, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := exclude[path]; ok {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			fmt.Fprintf(h, "dir %q\n", path)
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "link %q %q\n", path, target)
+		default:
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			if isGenerated(path) {
+				fmt.Fprintf(generated, "file %q %s\n", path, sum)
+			} else {
+				fmt.Fprintf(h, "file %q %s\n", path, sum)
+			}
+		}
+		return nil
+	})
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package qsbuild
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCachedPhase(t *testing.T) {
+	b := newFixture(t, map[string]string{
+		"src/a.go":       "package src",
+		"src/ignored/b":  "b",
+		"src/gen.go":     "// Code generated by test. DO NOT EDIT.\n",
+		"assets/out.txt": "out",
+	})
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	param := "a"
+	inputs := func() phaseInputs {
+		return phaseInputs{params: []string{param}, paths: []string{"src"},
+			exclude: []string{"src/ignored"}, generated: []string{"gen"},
+			outputs: []string{"assets/out.txt"}}
+	}
+	fail := false
+	runs := 0
+	f := func() {
+		runs++
+		writeTestFiles(t, b.root, map[string]string{"gen/code.go": "package gen", "assets/out.txt": "out"})
+		if fail {
+			b.logError("failed")
+			b.fail()
+		}
+	}
+
+	for _, step := range []struct {
+		name   string
+		change func()
+		run    bool
+	}{
+		{"first run", func() {}, true},
+		{"unchanged", func() {}, false},
+		{"input modified", func() { writeTestFiles(t, b.root, map[string]string{"src/a.go": "package a"}) }, true},
+		{"input added", func() { writeTestFiles(t, b.root, map[string]string{"src/c.go": "package src"}) }, true},
+		{"input removed", func() { os.Remove(b.path("src", "c.go")) }, true},
+		{"excluded path modified", func() { writeTestFiles(t, b.root, map[string]string{"src/ignored/b": "c"}) }, false},
+		{"param changed", func() { param = "b" }, true},
+		{"generated file modified", func() { writeTestFiles(t, b.root, map[string]string{"gen/code.go": "package x"}) }, true},
+		// files with a generated code header below paths are hashed as outputs.
+		{"generated header modified", func() {
+			writeTestFiles(t, b.root, map[string]string{"src/gen.go": "// Code generated by x. DO NOT EDIT.\n"})
+		}, true},
+		{"output modified", func() { writeTestFiles(t, b.root, map[string]string{"assets/out.txt": "minified"}) }, false},
+		{"output removed", func() { os.Remove(b.path("assets", "out.txt")) }, true},
+		{"forced", func() { b.opts.Force = true }, true},
+		{"not forced", func() { b.opts.Force = false }, false},
+		{"failed", func() { param, fail = "c", true }, true},
+		{"after failure", func() { fail = false }, true},
+		{"reloaded cache", func() { b.loadCache() }, false},
+	} {
+		before := runs
+		step.change()
+		err := b.cachedPhase("Test", inputs, f)
+		if fail {
+			checkPhaseError(t, err, "Test", "failed")
+		} else if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if ran := runs > before; ran != step.run {
+			t.Errorf("%s: expected run: %v, ran: %v", step.name, step.run, ran)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package qsbuild
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// cleanupRegistry holds everything that must be undone when a Builder is
+// closed, whether its phases succeeded, failed or have been interrupted.
+type cleanupRegistry struct {
+	sync.Mutex
+	nextID int
+	ids    []int
+	funcs  map[int]func()
+	procs  map[*os.Process]struct{}
+	// interrupted is set when the Builder has been interrupted. Commands
+	// failing afterwards have been stopped by the Builder and do not need to be
+	// reported.
+	interrupted bool
+	done        bool
+}
+
+// onCleanup registers f to be called when the Builder is closed. Registered
+// functions are called in reverse order of their registration.
+// The returned function calls f immediately and unregisters it; it is to be
+// used when the resource is released regularly.
+func (b *Builder) onCleanup(f func()) func() {
+	b.cleanup.Lock()
+	defer b.cleanup.Unlock()
+	if b.cleanup.funcs == nil {
+		b.cleanup.funcs = make(map[int]func())
+	}
+	id := b.cleanup.nextID
+	b.cleanup.nextID++
+	b.cleanup.ids = append(b.cleanup.ids, id)
+	b.cleanup.funcs[id] = f
+	return func() {
+		b.cleanup.Lock()
+		_, ok := b.cleanup.funcs[id]
+		delete(b.cleanup.funcs, id)
+		b.cleanup.Unlock()
+		if ok {
+			f()
+		}
+	}
+}
+
+// runTracked runs cmd like cmd.Run, but stops the process if the Builder is
+// interrupted. In that case, the current phase fails.
+func (b *Builder) runTracked(cmd *exec.Cmd) error {
+	b.cleanup.Lock()
+	if b.cleanup.done {
+		b.cleanup.Unlock()
+		b.fail()
+	}
+	trackProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		b.cleanup.Unlock()
+		return err
+	}
+	if b.cleanup.procs == nil {
+		b.cleanup.procs = make(map[*os.Process]struct{})
+	}
+	b.cleanup.procs[cmd.Process] = struct{}{}
+	b.cleanup.Unlock()
+
+	err := cmd.Wait()
+
+	b.cleanup.Lock()
+	delete(b.cleanup.procs, cmd.Process)
+	interrupted := b.cleanup.interrupted
+	b.cleanup.Unlock()
+	if interrupted {
+		b.fail()
+	}
+	return err
+}
+
+// runCleanup stops all running child processes and calls all registered
+// cleanup functions. Only the first call has any effect.
+func (b *Builder) runCleanup() {
+	b.cleanup.Lock()
+	defer b.cleanup.Unlock()
+	if b.cleanup.done {
+		return
+	}
+	b.cleanup.done = true
+	for proc := range b.cleanup.procs {
+		stopProcess(proc)
+	}
+	for i := len(b.cleanup.ids) - 1; i >= 0; i-- {
+		if f, ok := b.cleanup.funcs[b.cleanup.ids[i]]; ok {
+			f()
+		}
+	}
+	b.cleanup.funcs = nil
+}
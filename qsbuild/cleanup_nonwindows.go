@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package qsbuild
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// trackProcessGroup starts cmd in its own process group so that stopping it
+// also stops all processes it spawned.
+func trackProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func stopProcess(proc *os.Process) {
+	if err := syscall.Kill(-proc.Pid, syscall.SIGKILL); err != nil {
+		proc.Kill()
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package qsbuild
+
+import (
+	"os"
+	"os/exec"
+)
+
+func trackProcessGroup(cmd *exec.Cmd) {}
+
+func stopProcess(proc *os.Process) {
+	proc.Kill()
+}
@@ -0,0 +1,225 @@
+package qsbuild
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// CollisionPolicy determines how packaging the assets handles an asset path
+// that is provided by more than one source.
+type CollisionPolicy int
+
+const (
+	// CollisionWarn reports each collision as warning and uses the file from
+	// the source with the highest precedence. This is the default.
+	CollisionWarn CollisionPolicy = iota
+	// CollisionError reports each collision as error and fails.
+	CollisionError
+	// CollisionOverride uses the file from the source with the highest
+	// precedence and reports the collision as information only.
+	CollisionOverride
+)
+
+// defaultAssetOrder is the precedence of asset sources used if
+// Options.AssetOrder is empty. It reflects the order in which the sources used
+// to be copied into assets/.
+var defaultAssetOrder = []string{"api", "web", "plugins"}
+
+// assetSource is a source of files copied into assets/.
+type assetSource struct {
+	// origin describes the source in messages.
+	origin string
+	// rank is the precedence of the source. On collision, the file of the
+	// source with the highest rank is used.
+	rank int
+}
+
+// assetFile is a file that a source provides for a path in assets/.
+type assetFile struct {
+	source *assetSource
+	// path is the absolute path of the file, empty for the web UI outputs which
+	// are in assets/ already.
+	path string
+}
+
+// checkAssetOrder validates Options.AssetOrder. Plugin IDs are checked when
+// the assets are collected since the plugins are not known yet.
+func (b *Builder) checkAssetOrder() {
+	if len(b.opts.AssetOrder) == 0 {
+		b.opts.AssetOrder = defaultAssetOrder
+		return
+	}
+	seen := make(map[string]struct{})
+	for _, item := range b.opts.AssetOrder {
+		if _, ok := seen[item]; ok {
+			b.logError("asset order: duplicate item '%s'", item)
+			b.fail()
+		}
+		seen[item] = struct{}{}
+	}
+	for _, item := range defaultAssetOrder {
+		if _, ok := seen[item]; !ok {
+			b.logError("asset order: missing item '%s'", item)
+			b.logError("the order must contain 'api', 'web' and 'plugins', and may contain plugin IDs")
+			b.fail()
+		}
+	}
+}
+
+// assetSources returns the sources of the api module, of web/assets and of
+// each plugin, ranked according to Options.AssetOrder. Plugins not given in
+// the order take the place of "plugins" in the order of plugins.yaml.
+func (b *Builder) assetSources(plugins Data) (api, web *assetSource, pluginSources []*assetSource) {
+	byID := make(map[string]int, len(plugins))
+	for i, p := range plugins {
+		byID[p.ID] = i
+	}
+	explicit := make(map[string]struct{})
+	for _, item := range b.opts.AssetOrder {
+		switch item {
+		case "api", "web", "plugins":
+		default:
+			if _, ok := byID[item]; !ok {
+				b.logError("asset order: unknown plugin '%s'", item)
+				b.fail()
+			}
+			explicit[item] = struct{}{}
+		}
+	}
+
+	pluginSources = make([]*assetSource, len(plugins))
+	rank := 0
+	next := func(origin string) *assetSource {
+		rank++
+		return &assetSource{origin: origin, rank: rank}
+	}
+	for _, item := range b.opts.AssetOrder {
+		switch item {
+		case "api":
+			api = next("api")
+		case "web":
+			web = next("web/assets")
+		case "plugins":
+			for i, p := range plugins {
+				if _, ok := explicit[p.ID]; !ok {
+					pluginSources[i] = next("plugin " + p.ID)
+				}
+			}
+		default:
+			pluginSources[byID[item]] = next("plugin " + item)
+		}
+	}
+	return
+}
+
+// collectAssets returns all files provided for each path in assets/ by the web
+// UI outputs, the api module, web/assets and the plugins.
+func (b *Builder) collectAssets(plugins Data, webUIOutputs map[string]struct{}) map[string][]assetFile {
+	files := make(map[string][]assetFile)
+	// the web UI outputs are in place already and cannot be overridden.
+	webUI := &assetSource{origin: "web UI", rank: len(b.opts.AssetOrder) + len(plugins) + 1}
+	for name := range webUIOutputs {
+		files[name] = []assetFile{{source: webUI}}
+	}
+
+	api, web, pluginSources := b.assetSources(plugins)
+	addDir := func(source *assetSource, dir, desc string) {
+		b.must(filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			// symlinks are skipped, as CopyDir does.
+			if err != nil || info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err == nil {
+				name := filepath.ToSlash(rel)
+				files[name] = append(files[name], assetFile{source: source, path: p})
+			}
+			return err
+		}), "failed to read "+desc+":")
+	}
+	addDir(api, b.apiAssetsDir(), "api resources")
+	addDir(web, b.path("web", "assets"), "`web/assets` folder")
+	for i, p := range plugins {
+		for _, a := range p.Assets.Files() {
+			name := path.Join(p.ID, a)
+			files[name] = append(files[name], assetFile{source: pluginSources[i],
+				path: filepath.Join(p.DirPath, "web", "assets", filepath.FromSlash(a))})
+		}
+	}
+	return files
+}
+
+// resolveAssetCollisions reports all paths in assets/ that are provided by more
+// than one source according to Options.AssetCollisions, and all files whose
+// path is a directory of another source. It returns the file to use for each
+// path.
+func (b *Builder) resolveAssetCollisions(files map[string][]assetFile) map[string]assetFile {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	chosen := make(map[string]assetFile, len(files))
+	collisions, failed := 0, false
+	for _, name := range names {
+		candidates := files[name]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].source.rank < candidates[j].source.rank
+		})
+		winner := candidates[len(candidates)-1]
+		chosen[name] = winner
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if other, ok := files[dir]; ok {
+				b.logError("assets/%s of %s conflicts with file assets/%s of %s",
+					name, winner.source.origin, dir, other[len(other)-1].source.origin)
+				failed = true
+			}
+		}
+		if len(candidates) == 1 {
+			continue
+		}
+		collisions++
+		var report func(msg string, args ...interface{})
+		// listed are the sources that are listed below the message.
+		listed := candidates[:len(candidates)-1]
+		switch {
+		case b.opts.AssetCollisions == CollisionError || winner.path == "":
+			report = b.logError
+			failed = true
+			listed = candidates
+			b.logError("assets/%s is provided by multiple sources:", name)
+		case b.opts.AssetCollisions == CollisionWarn:
+			report = b.logWarning
+			b.logWarning("assets/%s is provided by multiple sources, using the one from %s:",
+				name, winner.source.origin)
+		default:
+			report = func(msg string, args ...interface{}) {
+				b.logInfo(format(msg, args...))
+			}
+			report("assets/%s of %s overrides:", name, winner.source.origin)
+		}
+		for _, c := range listed {
+			if c.path == "" {
+				report("  %s: assets/%s", c.source.origin, name)
+			} else {
+				report("  %s: %s", c.source.origin, c.path)
+			}
+		}
+		if winner.path == "" {
+			b.logError("  the web UI's assets/%s cannot be overridden", name)
+		}
+	}
+	if failed {
+		if b.opts.AssetCollisions == CollisionError {
+			b.logError("remove the duplicate files or set the collision policy to warn or override")
+		}
+		b.fail()
+	}
+	if collisions > 0 && b.opts.Verbose {
+		b.logVerbose(format("resolved %d asset collisions", collisions))
+	}
+	return chosen
+}
@@ -0,0 +1,85 @@
+package qsbuild
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveAssetCollisions(t *testing.T) {
+	api := &assetSource{origin: "api", rank: 1}
+	web := &assetSource{origin: "web/assets", rank: 2}
+	plugin := &assetSource{origin: "plugin base", rank: 3}
+	webUI := &assetSource{origin: "web UI", rank: 4}
+	files := func(extra map[string][]assetFile) map[string][]assetFile {
+		ret := map[string][]assetFile{
+			"index.html":   {{source: webUI}},
+			"base/a.css":   {{source: plugin, path: "/plugins/base/web/assets/a.css"}},
+			"fonts/a.woff": {{source: api, path: "/api/resources/fonts/a.woff"}},
+		}
+		for name, candidates := range extra {
+			ret[name] = candidates
+		}
+		return ret
+	}
+	// collision of a file in web/assets overriding the api's file.
+	override := map[string][]assetFile{"style.css": {
+		{source: web, path: "/web/assets/style.css"},
+		{source: api, path: "/api/resources/style.css"}}}
+
+	for _, tc := range []struct {
+		name     string
+		policy   CollisionPolicy
+		files    map[string][]assetFile
+		chosen   map[string]string
+		warnings bool
+		// logged are the messages expected in the recorded errors or, if
+		// warnings is set, warnings.
+		logged []string
+		failed bool
+	}{
+		{"no collisions", CollisionError, files(nil), map[string]string{
+			"index.html": "", "base/a.css": "/plugins/base/web/assets/a.css",
+			"fonts/a.woff": "/api/resources/fonts/a.woff"}, false, nil, false},
+		{"warn", CollisionWarn, files(override),
+			map[string]string{"style.css": "/web/assets/style.css"}, true, []string{
+				"assets/style.css is provided by multiple sources, using the one from web/assets:",
+				"  api: /api/resources/style.css"}, false},
+		{"override", CollisionOverride, files(override),
+			map[string]string{"style.css": "/web/assets/style.css"}, true, nil, false},
+		{"error", CollisionError, files(override), nil, false, []string{
+			"assets/style.css is provided by multiple sources:",
+			"  api: /api/resources/style.css",
+			"  web/assets: /web/assets/style.css",
+			"remove the duplicate files or set the collision policy to warn or override"}, true},
+		{"web UI output", CollisionOverride, files(map[string][]assetFile{"index.html": {
+			{source: webUI}, {source: web, path: "/web/assets/index.html"}}}), nil, false, []string{
+			"assets/index.html is provided by multiple sources:",
+			"  web/assets: /web/assets/index.html",
+			"  web UI: assets/index.html",
+			"  the web UI's assets/index.html cannot be overridden"}, true},
+		{"file and directory", CollisionOverride, files(map[string][]assetFile{"fonts": {
+			{source: plugin, path: "/plugins/base/web/assets/fonts"}}}), nil, false, []string{
+			"assets/fonts/a.woff of api conflicts with file assets/fonts of plugin base"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var log strings.Builder
+			b := New(Options{AssetCollisions: tc.policy,
+				Logger: recordingLogger{testLogger{t}, &log, tc.warnings}})
+			var chosen map[string]assetFile
+			err := b.run("Assets", func() { chosen = b.resolveAssetCollisions(tc.files) })
+			if tc.failed {
+				checkPhaseError(t, err, "Assets", "")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if logged := strings.TrimSuffix(log.String(), "\n"); logged != strings.Join(tc.logged, "\n") {
+				t.Errorf("expected messages:\n%s\ngot:\n%s", strings.Join(tc.logged, "\n"), logged)
+			}
+			for name, expected := range tc.chosen {
+				if file, ok := chosen[name]; !ok || file.path != expected {
+					t.Errorf("assets/%s: expected %q, got %+v", name, expected, file)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package qsbuild
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"text/template"
+	"time"
+)
+
+var versioninfoTmpl = template.Must(template.New("versioninfo").Parse(`
+package versioninfo
+
+var CurrentVersion = "{{.Version}}"
+var Date = "{{.Date}}"
+`))
+
+func (b *Builder) genVersionInfo(out io.Writer) string {
+	data := struct {
+		Version string
+		Date    time.Time
+	}{b.runAndCheck(b.command("git", "describe"), func(err error, stderr string) {
+		b.logError("failed to determine version with `git describe`:")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	}), time.Now()}
+
+	b.must(versioninfoTmpl.Execute(out, data), "failed to write version info:")
+	return data.Version
+}
+
+func (b *Builder) writeVersionInfo() string {
+	b.must(os.MkdirAll(b.path("versioninfo"), 0755))
+
+	out, err := os.Create(b.path("versioninfo", "versioninfo.go"))
+	b.must(err)
+	defer out.Close()
+	return b.genVersionInfo(out)
+}
+
+func (b *Builder) isWorkingDir() bool {
+	info, err := os.Stat(b.path(".git"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			b.must(err)
+		}
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+	return false
+}
+
+func (b *Builder) compileQuestscreen() {
+	if b.isWorkingDir() {
+		b.logInfo("development mode (in git repository)")
+		b.writeVersionInfo()
+	} else {
+		_, err := os.Stat(b.path("versioninfo", "versioninfo.go"))
+		b.must(err, "cannot compile: not in git repository unable to access versioninfo/versioninfo.go:")
+		b.logInfo("release mode (not in git repository)")
+	}
+
+	var exeName string
+	if runtime.GOOS == "windows" {
+		exeName = b.path("questscreen.exe")
+	} else {
+		exeName = b.path("questscreen")
+	}
+	b.logInfo("compiling code")
+	cmd := b.goModCmd("build", "-o", exeName)
+	cmd.Dir = b.path("main")
+	b.runAndDumpIfVerbose(cmd,
+		func(err error, stderr string) {
+			b.logError("failed to compile QuestScreen:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+}
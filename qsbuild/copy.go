@@ -21,7 +21,7 @@
  * SOFTWARE.
  */
 
-package main
+package qsbuild
 
 import (
 	"fmt"
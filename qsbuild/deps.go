@@ -0,0 +1,80 @@
+package qsbuild
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// ensureAvailable installs the command at importPath, which is part of the
+// module modulePath, into GOBIN. The version in QuestScreen's build list is
+// used if QuestScreen depends on the module, else the latest version.
+func (b *Builder) ensureAvailable(importPath, modulePath string, buildList map[string]string) {
+	version := buildList[modulePath]
+	if version == "" {
+		version = "latest"
+	}
+	b.logInfo("ensuring availability of " + importPath + "@" + version)
+	cmd := b.command(b.goCmd, "install", importPath+"@"+version)
+	cmd.Env = append(os.Environ(), "GOBIN="+b.goBin)
+	b.runAndDumpIfVerbose(cmd, func(err error, stderr string) {
+		b.logError("failed to install " + importPath + ":")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	})
+}
+
+func (b *Builder) downloadGo11216() {
+	b.logInfo("downloading go1.12.16 SDK")
+	b.runAndDumpIfVerbose(exec.Command("go1.12.16", "download"), func(err error, stderr string) {
+		b.logError("failed to download go1.12.16 SDK:")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	})
+}
+
+func (b *Builder) installGo11216(buildList map[string]string) {
+	b.ensureAvailable("golang.org/dl/go1.12.16", "golang.org/dl", buildList)
+	b.downloadGo11216()
+}
+
+// depsInputs returns the inputs of ensureDepsAvailable. The installed tools are
+// part of them so that the phase runs again if one of them has been removed.
+func (b *Builder) depsInputs() phaseInputs {
+	tools := []string{"goimports", "askew"}
+	if !b.wasm {
+		tools = append(tools, "gopherjs")
+	}
+	in := phaseInputs{params: []string{b.opts.Web}, paths: []string{"go.mod", "go.sum"}}
+	for _, tool := range tools {
+		if runtime.GOOS == "windows" {
+			tool += ".exe"
+		}
+		in.generated = append(in.generated, filepath.Join(b.goBin, tool))
+	}
+	return in
+}
+
+func (b *Builder) ensureDepsAvailable() {
+	buildList := b.buildList()
+	b.ensureAvailable("golang.org/x/tools/cmd/goimports", "golang.org/x/tools", buildList)
+	b.ensureAvailable("github.com/flyx/askew", "github.com/flyx/askew", buildList)
+	if !b.wasm {
+		b.ensureAvailable("github.com/gopherjs/gopherjs", "github.com/gopherjs/gopherjs", buildList)
+		if _, err := exec.LookPath("go1.12.16"); err != nil {
+			b.installGo11216(buildList)
+		} else {
+			// could be that the command is available but the SDK is not downloaded
+			cmd := exec.Command("go1.12.16", "version")
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := b.runTracked(cmd); err != nil {
+				b.downloadGo11216()
+			}
+		}
+	}
+}
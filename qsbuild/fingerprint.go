@@ -0,0 +1,202 @@
+package qsbuild
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// assetManifestName is the name of the JSON file in assets/ that maps logical
+// asset paths to fingerprinted paths.
+const assetManifestName = "asset-manifest.json"
+
+// cssRef matches references to other files in CSS: url(…) and @import "…".
+var cssRef = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// htmlRef matches quoted strings in HTML files, which includes attribute values
+// and string literals in inline scripts.
+var htmlRef = regexp.MustCompile(`"([^"<>\s]*)"|'([^'<>\s]*)'`)
+
+// entryDocument is the asset loaded by the browser first. It is never
+// fingerprinted since it is requested by its name; its references are
+// rewritten in place instead.
+const entryDocument = "index.html"
+
+// fingerprintedHash matches the hash inserted by fingerprintedName.
+var fingerprintedHash = regexp.MustCompile(`\.[0-9a-f]{12}(\.[^./]*)?$`)
+
+// fingerprintedName inserts the hash before the extension of name.
+func fingerprintedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// logicalName removes the hash inserted by fingerprintedName from name.
+func logicalName(name string) string {
+	return fingerprintedHash.ReplaceAllString(name, "$1")
+}
+
+// splitRef splits a reference into the path and a query or fragment suffix.
+// It returns false for references that do not point to a local file.
+func splitRef(ref string) (refPath, suffix string, ok bool) {
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "#") ||
+		strings.Contains(strings.SplitN(ref, "/", 2)[0], ":") {
+		return "", "", false
+	}
+	if i := strings.IndexAny(ref, "?#"); i != -1 {
+		return ref[:i], ref[i:], true
+	}
+	return ref, "", true
+}
+
+type fingerprinter struct {
+	b   *Builder
+	dir string
+	// assets contains all assets that are to be fingerprinted.
+	assets map[string]struct{}
+	// keep contains the assets that are copied instead of renamed.
+	keep map[string]struct{}
+	// manifest maps logical paths to fingerprinted paths of processed assets.
+	manifest map[string]string
+	// processing contains the CSS files currently being processed, to detect
+	// cyclic imports.
+	processing map[string]struct{}
+}
+
+// fingerprintAssets renames all assets in assets/ to contain the hash of their
+// content, so that they can be cached indefinitely. References in HTML and CSS
+// files are rewritten to the new names. The source map keeps its name since it
+// is referenced relative to main.js, index.html since it is the entry document.
+//
+// The assets in keep are the outputs of the web UI phase. They are copied
+// instead of renamed and left unmodified, so that the phase need not run again
+// for packaging the assets anew. index.html is rewritten in place nevertheless;
+// references to previously fingerprinted assets are updated when the assets
+// are packaged again, and the web UI phase writes it anew when fingerprinting
+// is turned off.
+//
+// fingerprintAssets returns the map from logical to fingerprinted paths, which
+// is also written to assets/asset-manifest.json.
+func (b *Builder) fingerprintAssets(keep map[string]struct{}) map[string]string {
+	f := fingerprinter{b: b, dir: b.path("assets"), assets: make(map[string]struct{}),
+		keep: keep, manifest: make(map[string]string), processing: make(map[string]struct{})}
+	for _, name := range b.listAssets() {
+		switch name {
+		case "main.js.map", entryDocument:
+		case assetManifestName:
+			b.logError("assets/%s: conflicts with the generated asset manifest", name)
+			b.fail()
+		default:
+			f.assets[name] = struct{}{}
+		}
+	}
+
+	for name := range f.assets {
+		f.process(name)
+	}
+	entry := filepath.Join(f.dir, entryDocument)
+	content, err := ioutil.ReadFile(entry)
+	b.must(err, "failed to read assets/"+entryDocument+":")
+	if rewritten := f.rewriteRefs(entryDocument, content); !bytes.Equal(rewritten, content) {
+		b.must(ioutil.WriteFile(entry, rewritten, 0644), "failed to write assets/"+entryDocument+":")
+	}
+
+	content, err = json.MarshalIndent(f.manifest, "", "  ")
+	b.must(err)
+	b.must(ioutil.WriteFile(filepath.Join(f.dir, assetManifestName), content, 0644),
+		"failed to write asset manifest:")
+	b.logInfo(format("fingerprinted %d assets", len(f.manifest)))
+	return f.manifest
+}
+
+// process fingerprints the asset with the given name after all assets it
+// references.
+func (f *fingerprinter) process(name string) string {
+	if hashed, ok := f.manifest[name]; ok {
+		return hashed
+	}
+	p := filepath.Join(f.dir, filepath.FromSlash(name))
+	content, err := ioutil.ReadFile(p)
+	f.b.must(err, "failed to read assets/"+name+":")
+	rewritten := f.rewriteRefs(name, content)
+	changed := !bytes.Equal(rewritten, content)
+	content = rewritten
+	sum := sha256.Sum256(content)
+	hashed := fingerprintedName(name, hex.EncodeToString(sum[:])[:12])
+	target := filepath.Join(f.dir, filepath.FromSlash(hashed))
+	if _, ok := f.keep[name]; ok || changed {
+		f.b.must(ioutil.WriteFile(target, content, 0644), "failed to write assets/"+hashed+":")
+		if !ok {
+			os.Remove(p)
+		}
+	} else {
+		f.b.must(os.Rename(p, target), "failed to rename assets/"+name+":")
+	}
+	f.manifest[name] = hashed
+	return hashed
+}
+
+// rewriteRefs returns the content of the asset with the given name with all
+// references to fingerprinted assets rewritten. Only HTML and CSS files are
+// rewritten; the content of other files is returned as-is.
+func (f *fingerprinter) rewriteRefs(name string, content []byte) []byte {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".css":
+		if _, ok := f.processing[name]; ok {
+			f.b.logError("assets/%s: cyclic import", name)
+			f.b.fail()
+		}
+		f.processing[name] = struct{}{}
+		defer delete(f.processing, name)
+		return cssRef.ReplaceAllFunc(content, func(match []byte) []byte {
+			groups := cssRef.FindSubmatch(match)
+			for _, g := range groups[1:] {
+				if len(g) > 0 {
+					return []byte(f.rewrite(string(match), path.Dir(name), string(g)))
+				}
+			}
+			return match
+		})
+	case ".html":
+		return htmlRef.ReplaceAllFunc(content, func(match []byte) []byte {
+			return []byte(f.rewrite(string(match), path.Dir(name),
+				string(match[1:len(match)-1])))
+		})
+	}
+	return content
+}
+
+// rewrite replaces ref inside match with the reference to the fingerprinted
+// asset if ref, relative to dir, references a fingerprinted asset.
+func (f *fingerprinter) rewrite(match, dir, ref string) string {
+	refPath, suffix, ok := splitRef(ref)
+	if !ok {
+		return match
+	}
+	var target string
+	if strings.HasPrefix(refPath, "/") {
+		target = strings.TrimPrefix(path.Clean(refPath), "/")
+	} else {
+		target = path.Join(dir, refPath)
+	}
+	if _, ok := f.assets[target]; !ok {
+		// the reference may have been rewritten by an earlier run.
+		target = logicalName(target)
+		if _, ok := f.assets[target]; !ok {
+			return match
+		}
+	}
+	hashed := f.process(target)
+	newRef := path.Join(path.Dir(refPath), path.Base(hashed))
+	if strings.HasPrefix(refPath, "./") {
+		newRef = "./" + newRef
+	}
+	return strings.Replace(match, ref, newRef+suffix, 1)
+}
@@ -0,0 +1,108 @@
+package qsbuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintKeepsEntryDocument(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"assets/index.html": `<link href="base/style.css"><script src="main.js"></script>`,
+		"assets/main.js":    "main();",
+	})
+	sources := map[string]string{
+		"assets/base/style.css": `body { background: url('bg.png'); }`,
+		"assets/base/bg.png":    "png",
+	}
+	keep := map[string]struct{}{"index.html": {}, "main.js": {}}
+
+	// the second run simulates packaging the assets again without running the
+	// web UI phase, which leaves the rewritten index.html in place.
+	for run := 1; run <= 2; run++ {
+		writeTestFiles(t, root, sources)
+		b := New(Options{Logger: testLogger{t}})
+		b.root = root
+		var manifest map[string]string
+		if err := b.run("Assets", func() { manifest = b.fingerprintAssets(keep) }); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if _, ok := manifest["index.html"]; ok {
+			t.Fatalf("run %d: index.html has been fingerprinted", run)
+		}
+		index, err := ioutil.ReadFile(filepath.Join(root, "assets", "index.html"))
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		for _, name := range []string{"base/style.css", "main.js"} {
+			hashed, ok := manifest[name]
+			if !ok {
+				t.Fatalf("run %d: %s has not been fingerprinted", run, name)
+			}
+			if !strings.Contains(string(index), `"`+hashed+`"`) {
+				t.Errorf("run %d: index.html does not reference %s: %s", run, hashed, index)
+			}
+			if _, err := os.Stat(filepath.Join(root, "assets", filepath.FromSlash(hashed))); err != nil {
+				t.Errorf("run %d: %v", run, err)
+			}
+		}
+		// the packaging cleans up everything but the web UI outputs.
+		for _, hashed := range manifest {
+			if _, ok := keep[hashed]; !ok {
+				os.Remove(filepath.Join(root, "assets", filepath.FromSlash(hashed)))
+			}
+		}
+		os.Remove(filepath.Join(root, "assets", assetManifestName))
+	}
+}
+
+func TestFingerprintTurnedOff(t *testing.T) {
+	b := newFixture(t, nil)
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	b.wasm = true
+	const index = `<script src="wasm_exec.js"></script><script src="main.wasm"></script>`
+	runs := 0
+	// buildWebUI is replaced since it requires askew and the QuestScreen sources.
+	webUI := func() {
+		runs++
+		writeTestFiles(t, b.root, map[string]string{
+			"assets/index.html":   index,
+			"assets/main.wasm":    "wasm",
+			"assets/wasm_exec.js": "exec();",
+		})
+	}
+	keep := map[string]struct{}{"index.html": {}, "main.wasm": {}, "wasm_exec.js": {}}
+
+	b.opts.Fingerprint = true
+	if err := b.cachedPhase("Web UI", b.webUIInputs, webUI); err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string]string
+	if err := b.run("Assets", func() { manifest = b.fingerprintAssets(keep) }); err != nil {
+		t.Fatal(err)
+	}
+	for _, hashed := range manifest {
+		os.Remove(filepath.Join(b.root, "assets", filepath.FromSlash(hashed)))
+	}
+	os.Remove(filepath.Join(b.root, "assets", assetManifestName))
+
+	b.opts.Fingerprint = false
+	if err := b.cachedPhase("Web UI", b.webUIInputs, webUI); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("expected the web UI phase to run again, ran %d times", runs)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(b.root, "assets", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != index {
+		t.Errorf("index.html still references fingerprinted assets: %s", content)
+	}
+}
@@ -0,0 +1,35 @@
+package qsbuild
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+func (b *Builder) writeFormatted(goCode string, file string) {
+	fmtcmd := exec.Command("goimports")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	fmtcmd.Stdout = &stdout
+	fmtcmd.Stderr = &stderr
+
+	stdin, err := fmtcmd.StdinPipe()
+	b.must(err, "unable to create stdin pipe:")
+	io.WriteString(stdin, goCode)
+	stdin.Close()
+
+	if err := b.runTracked(fmtcmd); err != nil {
+		b.logError("failed to format Go code:")
+		b.logError(err.Error())
+		b.logError("stderr output:")
+		b.writeErrorLines(stderr.String())
+		b.logError("input:")
+		b.writeErrorLines(goCode)
+		b.fail()
+	}
+
+	b.must(ioutil.WriteFile(file, stdout.Bytes(), os.ModePerm))
+}
@@ -0,0 +1,239 @@
+package qsbuild
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// lockedPlugin records the version an external plugin has been resolved to.
+// spec is `importPath[@version]` as given in the plugins file.
+type lockedPlugin struct {
+	id, spec, version string
+}
+
+// lockedModule is a go.sum entry of a module the plugins add to the build.
+// version may have the suffix `/go.mod` like in go.sum.
+type lockedModule struct {
+	path, version, sum string
+}
+
+// pluginsLock is the content of plugins.lock, which records the resolved
+// versions of external plugins and of all their dependencies, together with
+// their hashes, to make builds reproducible.
+type pluginsLock struct {
+	plugins []lockedPlugin
+	modules []lockedModule
+}
+
+const pluginsLockHeader = `# Code generated by qs-build. DO NOT EDIT.
+# Records the versions of external plugins and their dependencies.
+# Run ` + "`qs-build plugins update`" + ` to update.
+`
+
+func pluginSpec(descr *pluginDescr) string {
+	if descr.version == "" {
+		return descr.importPath
+	}
+	return descr.importPath + "@" + descr.version
+}
+
+// readPluginsLock reads the lock file at path.
+// It returns nil if the file does not exist.
+func readPluginsLock(path string) (*pluginsLock, []error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+	defer file.Close()
+	lock := &pluginsLock{}
+	var errs []error
+	scanner := bufio.NewScanner(file)
+	for lineCount := 1; scanner.Scan(); lineCount++ {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		items := strings.Fields(line)
+		switch {
+		case len(items) == 0:
+		case items[0] == "plugin" && len(items) == 4:
+			lock.plugins = append(lock.plugins, lockedPlugin{items[1], items[2], items[3]})
+		case items[0] == "module" && len(items) == 4:
+			lock.modules = append(lock.modules, lockedModule{items[1], items[2], items[3]})
+		default:
+			errs = append(errs, pluginsFileError{path, lineCount, "malformed line"})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return lock, errs
+}
+
+func (lock *pluginsLock) write(path string) error {
+	var b strings.Builder
+	b.WriteString(pluginsLockHeader)
+	b.WriteString("\n")
+	for _, p := range lock.plugins {
+		fmt.Fprintf(&b, "plugin %s %s %s\n", p.id, p.spec, p.version)
+	}
+	b.WriteString("\n")
+	for _, m := range lock.modules {
+		fmt.Fprintf(&b, "module %s %s %s\n", m.path, m.version, m.sum)
+	}
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// check returns a description of each difference between the plugins in the
+// lock and the given plugins. Plugins with a local path are not locked.
+func (lock *pluginsLock) check(plugins []pluginDescr) []string {
+	var ret []string
+	locked := make(map[string]lockedPlugin)
+	for _, p := range lock.plugins {
+		locked[p.id] = p
+	}
+	for i := range plugins {
+		descr := &plugins[i]
+		if descr.localPath != "" {
+			continue
+		}
+		p, ok := locked[descr.id]
+		if !ok {
+			ret = append(ret, fmt.Sprintf("plugin `%s` is missing", descr.id))
+		} else if p.spec != pluginSpec(descr) {
+			ret = append(ret, fmt.Sprintf("plugin `%s` is locked as `%s`, but given as `%s`",
+				descr.id, p.spec, pluginSpec(descr)))
+		}
+		delete(locked, descr.id)
+	}
+	for id := range locked {
+		ret = append(ret, fmt.Sprintf("plugin `%s` is not in the plugins file", id))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// apply adds the lock's hashes to the session's go.sum and requires the
+// locked version of each module, so that go verifies the downloaded modules
+// against the lock. Modules that QuestScreen requires in a higher version than
+// the locked one are not downgraded.
+func (lock *pluginsLock) apply(b *Builder) {
+	if len(lock.modules) == 0 {
+		return
+	}
+	current := b.buildList()
+	sumPath := filepath.Join(b.sessionModDir, "go.sum")
+	sums, err := os.OpenFile(sumPath, os.O_APPEND|os.O_WRONLY, 0644)
+	b.must(err, "failed to open temporary go.sum:")
+	var getPaths []string
+	for _, m := range lock.modules {
+		if _, err = fmt.Fprintf(sums, "%s %s %s\n", m.path, m.version, m.sum); err != nil {
+			sums.Close()
+			b.must(err, "failed to write temporary go.sum:")
+		}
+		if strings.HasSuffix(m.version, "/go.mod") {
+			continue
+		}
+		if v, ok := current[m.path]; ok && semver.Compare(v, m.version) > 0 {
+			if b.opts.Verbose {
+				b.logVerbose(format("keeping %s@%s, which is newer than the locked %s",
+					m.path, v, m.version))
+			}
+			continue
+		}
+		getPaths = append(getPaths, m.path+"@"+m.version)
+	}
+	b.must(sums.Close(), "failed to write temporary go.sum:")
+	if len(getPaths) == 0 {
+		return
+	}
+	b.runAndCheck(b.goModCmd(append([]string{"get"}, getPaths...)...),
+		func(err error, stderr string) {
+			b.logError("failed to load module versions from plugins.lock:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+}
+
+// buildList returns the version of each module in the build list of the
+// session's QuestScreen module.
+func (b *Builder) buildList() map[string]string {
+	out := b.runAndCheck(b.goModCmd("list", "-m", "-f", "{{.Path}} {{.Version}}", "all"),
+		func(err error, stderr string) {
+			b.logError("failed to list modules:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+	ret := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if items := strings.Fields(line); len(items) == 2 {
+			ret[items[0]] = items[1]
+		}
+	}
+	return ret
+}
+
+// createPluginsLock creates a lock for the given resolved plugins.
+// before and after are the build lists before and after the plugins have been
+// resolved; all modules whose version has changed are recorded in the lock
+// along with the plugins themselves.
+func (b *Builder) createPluginsLock(plugins []pluginDescr, before, after map[string]string) *pluginsLock {
+	lock := &pluginsLock{}
+	local := make(map[string]bool)
+	for i := range plugins {
+		descr := &plugins[i]
+		if descr.localPath != "" {
+			local[descr.importPath] = true
+			continue
+		}
+		lock.plugins = append(lock.plugins, lockedPlugin{
+			id: descr.id, spec: pluginSpec(descr), version: descr.modVersion})
+	}
+	sort.Slice(lock.plugins, func(i, j int) bool {
+		return lock.plugins[i].id < lock.plugins[j].id
+	})
+
+	changed := make(map[string]string)
+	for path, version := range after {
+		if !local[path] && version != "" && before[path] != version {
+			changed[path] = version
+		}
+	}
+	// plugins are always locked, even if QuestScreen already depends on them.
+	for i := range plugins {
+		if plugins[i].localPath == "" {
+			changed[plugins[i].importPath] = plugins[i].modVersion
+		}
+	}
+	content, err := ioutil.ReadFile(filepath.Join(b.sessionModDir, "go.sum"))
+	b.must(err, "failed to read temporary go.sum:")
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		items := strings.Fields(line)
+		if len(items) != 3 || seen[line] {
+			continue
+		}
+		if version, ok := changed[items[0]]; ok &&
+			(items[1] == version || items[1] == version+"/go.mod") {
+			seen[line] = true
+			lock.modules = append(lock.modules, lockedModule{items[0], items[1], items[2]})
+		}
+	}
+	sort.Slice(lock.modules, func(i, j int) bool {
+		if lock.modules[i].path != lock.modules[j].path {
+			return lock.modules[i].path < lock.modules[j].path
+		}
+		return lock.modules[i].version < lock.modules[j].version
+	})
+	return lock
+}
@@ -0,0 +1,81 @@
+package qsbuild
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPluginsLock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugins.lock")
+	if lock, errs := readPluginsLock(path); lock != nil || errs != nil {
+		t.Fatalf("expected no lock for a missing file, got %v, %v", lock, errs)
+	}
+
+	lock := &pluginsLock{
+		plugins: []lockedPlugin{{"dice", "example.com/dice@v1", "v1.2.0"}},
+		modules: []lockedModule{{"example.com/dice", "v1.2.0", "h1:a="},
+			{"example.com/dice", "v1.2.0/go.mod", "h1:b="}},
+	}
+	if err := lock.write(path); err != nil {
+		t.Fatal(err)
+	}
+	read, errs := readPluginsLock(path)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if !reflect.DeepEqual(read, lock) {
+		t.Errorf("expected %+v, got %+v", lock, read)
+	}
+
+	writeTestFiles(t, dir, map[string]string{"plugins.lock": `# comment
+plugin dice example.com/dice
+plugin dice example.com/dice@v1 v1.2.0 # trailing comment
+module example.com/dice v1.2.0
+modules example.com/dice v1.2.0 h1:a=
+`})
+	_, errs = readPluginsLock(path)
+	expected := []string{path + ":2: malformed line", path + ":4: malformed line",
+		path + ":5: malformed line"}
+	if fmt.Sprint(errs) != fmt.Sprint(expected) {
+		t.Errorf("expected errors %v, got %v", expected, errs)
+	}
+}
+
+func TestPluginsLockCheck(t *testing.T) {
+	lock := &pluginsLock{plugins: []lockedPlugin{
+		{"dice", "example.com/dice", "v1.0.0"},
+		{"cards", "example.com/cards@v2", "v2.1.0"},
+		{"map", "example.com/map", "v0.1.0"},
+	}}
+	for _, tc := range []struct {
+		name       string
+		plugins    []pluginDescr
+		mismatches []string
+	}{
+		{"matching", []pluginDescr{
+			{id: "dice", importPath: "example.com/dice"},
+			{id: "cards", importPath: "example.com/cards", version: "v2"},
+			{id: "map", importPath: "example.com/map"},
+			{id: "local", importPath: "example.com/local", localPath: "/local"},
+		}, nil},
+		{"changed", []pluginDescr{
+			{id: "dice", importPath: "example.com/dice", version: "v1.1.0"},
+			{id: "cards", importPath: "example.com/cards", version: "v2"},
+			{id: "map", importPath: "example.com/map", localPath: "/map"},
+			{id: "new", importPath: "example.com/new"},
+		}, []string{
+			"plugin `dice` is locked as `example.com/dice`, but given as `example.com/dice@v1.1.0`",
+			"plugin `map` is not in the plugins file",
+			"plugin `new` is missing",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := lock.check(tc.plugins); !reflect.DeepEqual(actual, tc.mismatches) {
+				t.Errorf("expected %q, got %q", tc.mismatches, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package qsbuild
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestError describes a single problem in a questscreen-plugin.yaml file
+// or in the assets it declares.
+type manifestError struct {
+	path         string
+	line, column int
+	msg          string
+}
+
+func (e manifestError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("%s: %s", e.path, e.msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.path, e.line, e.column, e.msg)
+}
+
+// manifestErrors is the list of all problems found in a manifest.
+type manifestErrors []manifestError
+
+func (e manifestErrors) Error() string {
+	lines := make([]string, len(e))
+	for i := range e {
+		lines[i] = e[i].Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type manifestValidator struct {
+	path   string
+	errors manifestErrors
+}
+
+func (v *manifestValidator) errorf(node *yaml.Node, format string, a ...interface{}) {
+	v.errors = append(v.errors, manifestError{path: v.path, line: node.Line,
+		column: node.Column, msg: fmt.Sprintf(format, a...)})
+}
+
+// nodeCheck validates a YAML node against the expected structure.
+// context describes the location of the node for error messages.
+type nodeCheck func(v *manifestValidator, node *yaml.Node, context string)
+
+// manifestField describes a key that may occur in a mapping of the manifest.
+type manifestField struct {
+	name     string
+	required bool
+	check    nodeCheck
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+func kindName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a sequence"
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "null"
+		}
+		return "a scalar"
+	default:
+		return "an unknown node"
+	}
+}
+
+func checkString(v *manifestValidator, node *yaml.Node, context string) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		v.errorf(node, "%s: expected a string, got %s", context, kindName(node))
+	}
+}
+
+// checkVersionConstraint accepts an optional version constraint.
+func checkVersionConstraint(v *manifestValidator, node *yaml.Node, context string) {
+	node = resolveAlias(node)
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	if node.Kind != yaml.ScalarNode {
+		v.errorf(node, "%s: expected a version constraint, got %s", context, kindName(node))
+	} else if _, err := parseVersionConstraint(node.Value); err != nil {
+		v.errorf(node, "%s: %s", context, err.Error())
+	}
+}
+
+// checkGlob accepts a glob pattern as described at assetRules.
+func checkGlob(v *manifestValidator, node *yaml.Node, context string) {
+	checkString(v, node, context)
+	node = resolveAlias(node)
+	if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return
+	}
+	if _, err := path.Match(node.Value, ""); err != nil {
+		v.errorf(node, "%s: invalid pattern `%s`", context, node.Value)
+		return
+	}
+	for _, segment := range strings.Split(node.Value, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, "\\") {
+			v.errorf(node, "%s: pattern `%s` must be relative to web/assets and use '/' as separator",
+				context, node.Value)
+			return
+		}
+	}
+}
+
+// checkConfig accepts any mapping since configuration values are interpreted
+// by the modules and not by qs-build.
+func checkConfig(v *manifestValidator, node *yaml.Node, context string) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode && node.Tag != "!!null" {
+		v.errorf(node, "%s: expected a mapping, got %s", context, kindName(node))
+	}
+}
+
+func checkSequence(item nodeCheck) nodeCheck {
+	return func(v *manifestValidator, node *yaml.Node, context string) {
+		node = resolveAlias(node)
+		if node.Kind != yaml.SequenceNode {
+			if node.Tag != "!!null" {
+				v.errorf(node, "%s: expected a sequence, got %s", context, kindName(node))
+			}
+			return
+		}
+		for i, child := range node.Content {
+			item(v, child, fmt.Sprintf("%s[%d]", context, i))
+		}
+	}
+}
+
+// checkMap validates a mapping from IDs to values.
+func checkMap(value nodeCheck) nodeCheck {
+	return func(v *manifestValidator, node *yaml.Node, context string) {
+		node = resolveAlias(node)
+		if node.Kind != yaml.MappingNode {
+			if node.Tag != "!!null" {
+				v.errorf(node, "%s: expected a mapping, got %s", context, kindName(node))
+			}
+			return
+		}
+		seen := make(map[string]struct{})
+		for i := 0; i < len(node.Content); i += 2 {
+			key := resolveAlias(node.Content[i])
+			if key.Kind != yaml.ScalarNode || key.Value == "" {
+				v.errorf(key, "%s: expected a non-empty ID as key", context)
+				continue
+			}
+			if _, ok := seen[key.Value]; ok {
+				v.errorf(key, "%s: duplicate ID `%s`", context, key.Value)
+				continue
+			}
+			seen[key.Value] = struct{}{}
+			value(v, node.Content[i+1], context+"."+key.Value)
+		}
+	}
+}
+
+// checkStruct validates a mapping with a fixed set of keys.
+// Unknown keys are reported as errors.
+func checkStruct(fields ...manifestField) nodeCheck {
+	return func(v *manifestValidator, node *yaml.Node, context string) {
+		node = resolveAlias(node)
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "%s: expected a mapping, got %s", context, kindName(node))
+			return
+		}
+		found := make([]bool, len(fields))
+	keys:
+		for i := 0; i < len(node.Content); i += 2 {
+			key := resolveAlias(node.Content[i])
+			for j := range fields {
+				if fields[j].name == key.Value {
+					if found[j] {
+						v.errorf(key, "%s: duplicate key `%s`", context, key.Value)
+					} else {
+						found[j] = true
+						fields[j].check(v, node.Content[i+1], context+"."+key.Value)
+					}
+					continue keys
+				}
+			}
+			for j := range fields {
+				if strings.EqualFold(fields[j].name, key.Value) {
+					v.errorf(key, "%s: unknown key `%s` (did you mean `%s`?)",
+						context, key.Value, fields[j].name)
+					continue keys
+				}
+			}
+			v.errorf(key, "%s: unknown key `%s`", context, key.Value)
+		}
+		for j := range fields {
+			if fields[j].required && !found[j] {
+				v.errorf(node, "%s: missing required key `%s`", context, fields[j].name)
+			}
+		}
+	}
+}
+
+// pluginManifestSchema describes the structure of questscreen-plugin.yaml.
+// It must be kept in sync with PluginDescr.
+var pluginManifestSchema = checkStruct(
+	manifestField{name: "name", required: true, check: checkString},
+	manifestField{name: "modules", required: true,
+		check: checkSequence(checkString)},
+	manifestField{name: "requires", check: checkMap(checkVersionConstraint)},
+	manifestField{name: "api", check: checkVersionConstraint},
+	manifestField{name: "assets", check: checkStruct(
+		manifestField{name: "include", check: checkSequence(checkGlob)},
+		manifestField{name: "exclude", check: checkSequence(checkGlob)},
+		manifestField{name: "css", check: checkSequence(checkGlob)},
+		manifestField{name: "js", check: checkSequence(checkGlob)},
+		manifestField{name: "fonts", check: checkSequence(checkGlob)},
+		manifestField{name: "images", check: checkSequence(checkGlob)},
+	)},
+	manifestField{name: "templates", check: checkStruct(
+		manifestField{name: "groups", check: checkSequence(checkStruct(
+			manifestField{name: "name", required: true, check: checkString},
+			manifestField{name: "description", check: checkString},
+			manifestField{name: "config", check: checkConfig},
+			manifestField{name: "scenes", check: checkSequence(checkStruct(
+				manifestField{name: "name", required: true, check: checkString},
+				manifestField{name: "template", required: true, check: checkString},
+			))},
+		))},
+		manifestField{name: "scenes", check: checkMap(checkStruct(
+			manifestField{name: "name", required: true, check: checkString},
+			manifestField{name: "description", check: checkString},
+			manifestField{name: "config", check: checkConfig},
+		))},
+		manifestField{name: "systems", check: checkMap(checkStruct(
+			manifestField{name: "name", required: true, check: checkString},
+			manifestField{name: "config", check: checkConfig},
+		))},
+	)},
+)
+
+// loadManifest parses and validates the content of a questscreen-plugin.yaml
+// file at path and loads it into p. If the manifest is invalid, the returned
+// error is of type manifestErrors and lists every problem found.
+func loadManifest(path string, content []byte, p *PluginDescr) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return manifestErrors{{path: path, msg: err.Error()}}
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return manifestErrors{{path: path, msg: "manifest is empty"}}
+	}
+	v := manifestValidator{path: path}
+	pluginManifestSchema(&v, root.Content[0], "plugin")
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	if err := root.Content[0].Decode(p); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, msg := range typeErr.Errors {
+				v.errors = append(v.errors, manifestError{path: path, msg: msg})
+			}
+			return v.errors
+		}
+		return manifestErrors{{path: path, msg: err.Error()}}
+	}
+	p.Templates.sceneOrder = mappingKeys(root.Content[0], "templates", "scenes")
+	p.Templates.systemOrder = mappingKeys(root.Content[0], "templates", "systems")
+	return nil
+}
+
+// mappingKeys follows the given keys from node and returns the keys of the
+// mapping found there in the order they are declared.
+func mappingKeys(node *yaml.Node, path ...string) []string {
+	node = resolveAlias(node)
+	if len(path) > 0 {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i < len(node.Content); i += 2 {
+				if resolveAlias(node.Content[i]).Value == path[0] {
+					return mappingKeys(node.Content[i+1], path[1:]...)
+				}
+			}
+		}
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	ret := make([]string, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		ret = append(ret, resolveAlias(node.Content[i]).Value)
+	}
+	return ret
+}
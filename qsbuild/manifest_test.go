@@ -0,0 +1,107 @@
+package qsbuild
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	p := PluginDescr{}
+	err := loadManifest("m.yaml", []byte(`name: Test
+modules: [a, b]
+requires: {base: ">= 1.2, < 2"}
+api: v0.3
+assets:
+  exclude: ["**/*.scss"]
+  css: [style.css]
+templates:
+  scenes:
+    zeta: {name: Zeta}
+    alpha: {name: Alpha, config: {mod: {value: 1}}}
+  systems:
+    sys: {name: System}
+  groups:
+    - name: Group
+      scenes: [{name: Scene, template: test.zeta}]
+`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Test" || !reflect.DeepEqual(p.Modules, []string{"a", "b"}) ||
+		p.Requires["base"] != ">= 1.2, < 2" || p.API != "v0.3" {
+		t.Errorf("unexpected plugin description: %+v", p)
+	}
+	if !reflect.DeepEqual(p.AssetRules, assetRules{Exclude: []string{"**/*.scss"},
+		CSS: []string{"style.css"}}) {
+		t.Errorf("unexpected asset rules: %+v", p.AssetRules)
+	}
+	// scenes and systems keep the order of the manifest.
+	if !reflect.DeepEqual(p.Templates.sceneOrder, []string{"zeta", "alpha"}) ||
+		!reflect.DeepEqual(p.Templates.systemOrder, []string{"sys"}) {
+		t.Errorf("unexpected template order: %v, %v",
+			p.Templates.sceneOrder, p.Templates.systemOrder)
+	}
+	if len(p.Templates.Groups) != 1 || p.Templates.Groups[0].Scenes[0].Template != "test.zeta" {
+		t.Errorf("unexpected group templates: %+v", p.Templates.Groups)
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, content string
+		errors        []string
+	}{
+		{"empty", "", []string{"m.yaml: manifest is empty"}},
+		{"syntax", "name: [a\n", []string{"m.yaml: yaml: line 1: did not find expected ',' or ']'"}},
+		{"not a mapping", "- a\n", []string{"m.yaml:1:1: plugin: expected a mapping, got a sequence"}},
+		{"missing keys", "api: v1\n", []string{
+			"m.yaml:1:1: plugin: missing required key `name`",
+			"m.yaml:1:1: plugin: missing required key `modules`"}},
+		{"unknown keys", "name: a\nmodules: []\nModules: []\nfoo: 1\n", []string{
+			"m.yaml:3:1: plugin: unknown key `Modules` (did you mean `modules`?)",
+			"m.yaml:4:1: plugin: unknown key `foo`"}},
+		{"wrong kinds", "name: {a: b}\nmodules: [a, [b]]\n", []string{
+			"m.yaml:1:7: plugin.name: expected a string, got a mapping",
+			"m.yaml:2:14: plugin.modules[1]: expected a string, got a sequence"}},
+		{"null name", "name:\nmodules: []\n", []string{
+			"m.yaml:1:6: plugin.name: expected a string, got null"}},
+		{"version constraints", "name: a\nmodules: []\napi: '>= x'\nrequires: {b: '1.0,', c: [1]}\n", []string{
+			"m.yaml:3:6: plugin.api: invalid semantic version `vx` in version constraint `>= x`",
+			"m.yaml:4:15: plugin.requires.b: empty comparison in version constraint `1.0,`",
+			"m.yaml:4:26: plugin.requires.c: expected a version constraint, got a sequence"}},
+		{"duplicate ID", "name: a\nmodules: []\ntemplates:\n  scenes:\n    s: {name: A}\n    s: {name: B}\n", []string{
+			"m.yaml:6:5: plugin.templates.scenes: duplicate ID `s`"}},
+		{"scene template", "name: a\nmodules: []\ntemplates:\n  scenes:\n    s: {config: 1}\n", []string{
+			"m.yaml:5:17: plugin.templates.scenes.s.config: expected a mapping, got a scalar",
+			"m.yaml:5:8: plugin.templates.scenes.s: missing required key `name`"}},
+		{"group scene", "name: a\nmodules: []\ntemplates:\n  groups:\n    - name: G\n      scenes: [{name: S}]\n", []string{
+			"m.yaml:6:16: plugin.templates.groups[0].scenes[0]: missing required key `template`"}},
+		{"globs", "name: a\nmodules: []\nassets:\n  include: ['[', ../a, /a, 'a\\b', a//b, 1]\n", []string{
+			"m.yaml:4:13: plugin.assets.include[0]: invalid pattern `[`",
+			"m.yaml:4:18: plugin.assets.include[1]: pattern `../a` must be relative to web/assets and use '/' as separator",
+			"m.yaml:4:24: plugin.assets.include[2]: pattern `/a` must be relative to web/assets and use '/' as separator",
+			"m.yaml:4:28: plugin.assets.include[3]: pattern `a\\b` must be relative to web/assets and use '/' as separator",
+			"m.yaml:4:35: plugin.assets.include[4]: pattern `a//b` must be relative to web/assets and use '/' as separator"}},
+		{"valid flow mappings", "name: a\nmodules: []\nassets: {css: [a], include: [b]}\nrequires: {x: 1}\n", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p PluginDescr
+			err := loadManifest("m.yaml", []byte(tc.content), &p)
+			if tc.errors == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			errs, ok := err.(manifestErrors)
+			if !ok {
+				t.Fatalf("expected manifestErrors, got %v", err)
+			}
+			if actual := strings.Split(errs.Error(), "\n"); !reflect.DeepEqual(actual, tc.errors) {
+				t.Errorf("unexpected errors:\n%s\nexpected:\n%s",
+					strings.Join(actual, "\n"), strings.Join(tc.errors, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package qsbuild
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+// minifyTypes maps the extensions of files that are minified to their MIME
+// type as known to the minifier.
+var minifyTypes = map[string]string{
+	".css": "text/css",
+	".js":  "application/javascript",
+	".svg": "image/svg+xml",
+}
+
+// compressedExts contains the extensions of files that are pre-compressed.
+// Other formats, like most images and WOFF fonts, are compressed already.
+var compressedExts = map[string]struct{}{
+	".wasm": {}, ".js": {}, ".css": {}, ".svg": {}, ".html": {}, ".json": {},
+	".map": {}, ".txt": {}, ".ttf": {}, ".otf": {}, ".ico": {},
+}
+
+// minCompressedSize is the size below which files are not pre-compressed since
+// the overhead of an encoded response would outweigh the savings.
+const minCompressedSize = 1024
+
+// assetEncoding is a content encoding in which assets are pre-compressed.
+type assetEncoding struct {
+	// name is the value of the Content-Encoding header.
+	name string
+	// ext is appended to the asset's file name to get the encoded file's name.
+	ext      string
+	compress func(w io.Writer) io.WriteCloser
+}
+
+// assetEncodings are the encodings assets are pre-compressed in, in order of
+// preference.
+var assetEncodings = []assetEncoding{
+	{name: "br", ext: ".br", compress: func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	}},
+	{name: "gzip", ext: ".gz", compress: func(w io.Writer) io.WriteCloser {
+		gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return gz
+	}},
+}
+
+// minifyAssets minifies CSS, JavaScript and SVG files in assets/ in place.
+func (b *Builder) minifyAssets() {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+
+	var names []string
+	for _, name := range b.listAssets() {
+		// minifying main.js would invalidate its source map.
+		if _, ok := minifyTypes[strings.ToLower(path.Ext(name))]; ok &&
+			!(b.opts.Debug && name == "main.js") {
+			names = append(names, name)
+		}
+	}
+	type result struct {
+		before, after int
+		err           error
+	}
+	results := make([]result, len(names))
+	b.parallel(len(names), func(i int) {
+		p := b.path("assets", filepath.FromSlash(names[i]))
+		res := &results[i]
+		var content []byte
+		if content, res.err = ioutil.ReadFile(p); res.err != nil {
+			return
+		}
+		res.before, res.after = len(content), len(content)
+		var min bytes.Buffer
+		if err := m.Minify(minifyTypes[strings.ToLower(path.Ext(p))], &min,
+			bytes.NewReader(content)); err != nil {
+			b.logWarning("unable to minify assets/%s, keeping it as-is: %s", names[i], err.Error())
+		} else if min.Len() < len(content) {
+			res.after = min.Len()
+			res.err = replaceFile(p, min.Bytes())
+		}
+	})
+
+	before, after, failed := 0, 0, false
+	for i, res := range results {
+		if res.err != nil {
+			b.logError("failed to minify assets/%s: %s", names[i], res.err.Error())
+			failed = true
+		}
+		before += res.before
+		after += res.after
+	}
+	if failed {
+		b.fail()
+	}
+	b.logInfo(format("minified %d assets from %d to %d bytes", len(names), before, after))
+}
+
+// compressAssets writes pre-compressed variants of all files in assets/ that
+// benefit from it. Originals of fingerprinted assets, which are not packaged,
+// are skipped. It returns the encodings that are available for each asset.
+func (b *Builder) compressAssets(manifest map[string]string) map[string][]string {
+	var names []string
+	for _, name := range b.listAssets() {
+		if _, ok := manifest[name]; ok {
+			continue
+		}
+		if _, ok := compressedExts[strings.ToLower(path.Ext(name))]; ok {
+			names = append(names, name)
+		}
+	}
+	type result struct {
+		encodings []string
+		err       error
+	}
+	results := make([]result, len(names))
+	b.parallel(len(names), func(i int) {
+		p := b.path("assets", filepath.FromSlash(names[i]))
+		res := &results[i]
+		var content []byte
+		if content, res.err = ioutil.ReadFile(p); res.err != nil ||
+			len(content) < minCompressedSize {
+			return
+		}
+		for _, enc := range assetEncodings {
+			var compressed bytes.Buffer
+			w := enc.compress(&compressed)
+			w.Write(content)
+			if res.err = w.Close(); res.err != nil {
+				return
+			}
+			// only keep variants that save at least a tenth of the size.
+			if compressed.Len() > len(content)*9/10 {
+				continue
+			}
+			if res.err = ioutil.WriteFile(p+enc.ext, compressed.Bytes(), 0644); res.err != nil {
+				return
+			}
+			res.encodings = append(res.encodings, enc.name)
+		}
+	})
+
+	encodings := make(map[string][]string)
+	failed := false
+	for i, res := range results {
+		if res.err != nil {
+			b.logError("failed to compress assets/%s: %s", names[i], res.err.Error())
+			failed = true
+		} else if len(res.encodings) > 0 {
+			encodings[names[i]] = res.encodings
+		}
+	}
+	if failed {
+		b.fail()
+	}
+	b.logInfo(format("pre-compressed %d assets", len(encodings)))
+	return encodings
+}
+
+// replaceFile writes content to the file at path. The file is removed first
+// since files copied from the module cache are read-only.
+func replaceFile(path string, content []byte) error {
+	os.Remove(path)
+	return ioutil.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,128 @@
+package qsbuild
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+type pluginDescr struct {
+	id, importPath, version, dir string
+	// modVersion is the module version the plugin has been resolved to.
+	modVersion string
+	// localPath is the directory given via `=> localPath`, if any.
+	// sum is the pinned module hash, if any.
+	localPath, sum string
+	line           int
+}
+
+// command creates a command that runs in the project root.
+func (b *Builder) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = b.root
+	return cmd
+}
+
+// goModCmd creates a go command that operates on the QuestScreen module.
+// args must start with the go subcommand, e.g. "build" or "mod edit".
+// The command uses the session's copy of go.mod and runs in the project root.
+func (b *Builder) goModCmd(args ...string) *exec.Cmd {
+	n := 1
+	if args[0] == "mod" {
+		n = 2
+	}
+	cmdArgs := make([]string, 0, len(args)+1)
+	cmdArgs = append(cmdArgs, args[:n]...)
+	cmdArgs = append(cmdArgs, "-modfile="+b.sessionModPath)
+	return b.command(b.goCmd, append(cmdArgs, args[n:]...)...)
+}
+
+// parallel calls f(i) for each i in [0, count) on up to b.opts.Jobs goroutines
+// and returns when all calls have finished.
+func (b *Builder) parallel(count int, f func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.opts.Jobs && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func (b *Builder) runAndCheck(cmd *exec.Cmd, errorHandler func(err error, stderr string)) string {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := b.runTracked(cmd); err != nil {
+		errorHandler(err, stderr.String())
+		output := strings.TrimSpace(stdout.String())
+		if len(output) > 0 {
+			b.logError("output:")
+			b.writeErrorLines(output)
+		}
+		b.fail()
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+func (b *Builder) writeErrorLines(stderr string) {
+	lines := strings.Split(stderr, "\n")
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		b.logError("… " + line)
+	}
+}
+
+func (b *Builder) runAndDumpIfVerbose(cmd *exec.Cmd, errorHandler func(err error, stderr string)) {
+	if b.opts.Verbose {
+		b.logVerbose(cmd.String())
+	}
+	stdout := b.runAndCheck(cmd, errorHandler)
+	if b.opts.Verbose && stdout != "" {
+		for _, line := range strings.Split(stdout, "\n") {
+			b.logVerbose(line)
+		}
+	}
+}
+
+func (b *Builder) checkRename(src, dst string) {
+	if err := os.Rename(src, dst); err != nil {
+		b.logError("while renaming '%s' to '%s':", src, dst)
+		b.logError(err.Error())
+		b.fail()
+	}
+}
+
+func (b *Builder) must(err error, errMsg ...string) {
+	if err != nil {
+		for _, msg := range errMsg {
+			b.logError(msg)
+		}
+		b.logError(err.Error())
+		b.fail()
+	}
+}
+
+func (b *Builder) mustCond(cond bool, errMsg ...string) {
+	if !cond {
+		for _, msg := range errMsg {
+			b.logError(msg)
+		}
+		b.fail()
+	}
+}
@@ -0,0 +1,108 @@
+package qsbuild
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Logger receives the messages of a Builder. Implementations must be safe for
+// concurrent use.
+type Logger interface {
+	// Phase is called when a phase starts.
+	Phase(name string)
+	Info(msg string)
+	// Verbose is only called if Options.Verbose is set.
+	Verbose(msg string)
+	Warning(msg string)
+	Error(msg string)
+}
+
+var blueBold = color.New(color.FgBlue).Add(color.Bold)
+var bold = color.New(color.FgWhite).Add(color.Bold)
+var yellowBold = color.New(color.FgYellow).Add(color.Bold)
+var redBold = color.New(color.FgRed).Add(color.Bold)
+
+type consoleLogger struct {
+	// mutex keeps messages logged from concurrent goroutines from
+	// interleaving.
+	mutex sync.Mutex
+}
+
+// NewConsoleLogger returns a Logger that writes colored messages to stdout.
+func NewConsoleLogger() Logger {
+	return &consoleLogger{}
+}
+
+func (l *consoleLogger) Phase(name string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	blueBold.Print("[phase] ")
+	color.Blue("%s", name)
+}
+
+func (l *consoleLogger) Info(msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	bold.Print("[info] ")
+	os.Stdout.WriteString(msg)
+	os.Stdout.WriteString("\n")
+}
+
+func (l *consoleLogger) Verbose(msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	bold.Print("[verbose] ")
+	os.Stdout.WriteString(msg)
+	os.Stdout.WriteString("\n")
+}
+
+func (l *consoleLogger) Warning(msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	yellowBold.Print("[warn] ")
+	color.Yellow("%s", msg)
+}
+
+func (l *consoleLogger) Error(msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	redBold.Print("[error] ")
+	color.Red("%s", msg)
+}
+
+// format formats msg like fmt.Sprintf if any arguments are given.
+func format(msg string, a ...interface{}) string {
+	if len(a) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, a...)
+}
+
+func (b *Builder) logPhase(msg string) {
+	b.log.Phase(msg)
+}
+
+func (b *Builder) logInfo(msg string) {
+	b.log.Info(msg)
+}
+
+func (b *Builder) logVerbose(msg string) {
+	b.log.Verbose(msg)
+}
+
+func (b *Builder) logWarning(msg string, a ...interface{}) {
+	b.log.Warning(format(msg, a...))
+}
+
+func (b *Builder) logError(msg string, a ...interface{}) {
+	msg = format(msg, a...)
+	b.errMutex.Lock()
+	if b.firstError == "" {
+		b.firstError = msg
+	}
+	b.errMutex.Unlock()
+	b.log.Error(msg)
+}
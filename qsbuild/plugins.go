@@ -0,0 +1,925 @@
+package qsbuild
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/QuestScreen/qs-build/inspector"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+type sceneTmplRef struct {
+	Name     string
+	Template string
+}
+
+type groupTmpl struct {
+	Name        string
+	Description string
+	Config      map[string]interface{}
+	Scenes      []sceneTmplRef
+}
+
+type sceneTmpl struct {
+	Name        string
+	Description string
+	Config      map[string]interface{}
+}
+
+type systemTmpl struct {
+	Name   string
+	Config map[string]interface{}
+}
+
+type pluginTemplates struct {
+	Groups  []groupTmpl
+	Scenes  map[string]sceneTmpl
+	Systems map[string]systemTmpl
+	// IDs of scenes and systems in the order they are declared in the manifest.
+	sceneOrder, systemOrder []string
+}
+
+// PluginDescr loads the content of a questscreen-plugin.yaml file.
+type PluginDescr struct {
+	Name, importPath, dirPath string
+	Modules                   []string
+	// Requires maps the IDs of plugins this plugin depends on to optional
+	// version constraints.
+	Requires map[string]string
+	// API is an optional constraint on the version of github.com/QuestScreen/api
+	// the plugin supports.
+	API       string
+	Templates pluginTemplates
+	// AssetRules selects and categorizes the files in web/assets.
+	AssetRules assetRules `yaml:"assets"`
+	assets     AssetData
+	// id is the ID under which the plugin has been discovered.
+	// version is the module version of the plugin, empty if unknown.
+	id, version string
+}
+
+// SceneTmplData is a scene template declared by a plugin.
+type SceneTmplData struct {
+	ID, Name, Description string
+	Config                map[string]interface{}
+}
+
+// SystemTmplData is a system template declared by a plugin.
+type SystemTmplData struct {
+	ID, Name string
+	Config   map[string]interface{}
+}
+
+// SceneTmplRefData references a scene template from a group template.
+// PluginIndex and TmplIndex locate the template in Data.
+type SceneTmplRefData struct {
+	Name, Template         string
+	PluginIndex, TmplIndex int
+}
+
+// GroupTmplData is a group template declared by a plugin.
+type GroupTmplData struct {
+	Name, Description string
+	Config            map[string]interface{}
+	Scenes            []SceneTmplRefData
+}
+
+// PluginTemplateData holds the templates declared by a plugin.
+type PluginTemplateData struct {
+	Groups  []GroupTmplData
+	Scenes  []SceneTmplData
+	Systems []SystemTmplData
+}
+
+// ModuleData is a module of a plugin. ImportName is the name under which the
+// generated code imports the module's package.
+type ModuleData struct {
+	ImportName, Name string
+}
+
+// PluginData holds the processed metadata of a plugin.
+type PluginData struct {
+	ImportPath, DirPath, ID, Name string
+	Modules                       []ModuleData
+	Templates                     PluginTemplateData
+	Assets                        AssetData
+}
+
+// Data holds the processed metadata for plugins.
+// All maps are transformed into slices for reproducible indexing:
+// Plugins are in load order, scene and system templates are ordered as they
+// are declared in the plugin's manifest.
+type Data []PluginData
+
+func (b *Builder) discoverPlugin(importPath, path string) (PluginDescr, error) {
+	yamlPath := filepath.Join(path, "questscreen-plugin.yaml")
+	info, err := os.Stat(yamlPath)
+	if err != nil {
+		return PluginDescr{}, errors.New(importPath + ": " + err.Error())
+	}
+	if info.IsDir() {
+		return PluginDescr{}, errors.New(importPath + ": is a directory")
+	}
+	description, err := ioutil.ReadFile(yamlPath)
+	if err != nil {
+		return PluginDescr{}, errors.New(importPath + ": " + err.Error())
+	}
+	p := PluginDescr{importPath: importPath, dirPath: path}
+	if err = loadManifest(yamlPath, description, &p); err != nil {
+		return PluginDescr{}, err
+	}
+	assetsPath := filepath.Join(path, "web", "assets")
+	var files []string
+	info, err = os.Stat(assetsPath)
+	if err == nil && info.IsDir() {
+		if files, err = listAssetFiles(assetsPath); err != nil {
+			return PluginDescr{}, err
+		}
+	}
+	if p.assets, err = b.applyAssetRules(yamlPath, p.AssetRules, files); err != nil {
+		return PluginDescr{}, err
+	}
+	return p, nil
+}
+
+// reportDiscoveryError logs an error returned by discoverPlugin. Invalid
+// manifests are errors and make it return true, other problems cause the
+// plugin to be skipped with a warning.
+func (b *Builder) reportDiscoveryError(name string, err error) bool {
+	if errs, ok := err.(manifestErrors); ok {
+		for _, e := range errs {
+			b.logError(e.Error())
+		}
+		return true
+	}
+	b.logWarning(err.Error())
+	b.logWarning(name + ": skipping")
+	return false
+}
+
+// discoverPlugins loads all plugins in the project's plugins directory and the
+// plugins given via the plugins file and returns them in load order.
+// All problems are logged; the returned bool is false if any problem has been
+// found. Plugins whose manifest is invalid are not returned; their IDs are
+// returned as broken so that references to them are not reported again. If the
+// load order cannot be determined, the plugins are returned ordered by ID.
+func (b *Builder) discoverPlugins() (plugins []PluginDescr, broken map[string]struct{}, ok bool) {
+	b.logInfo("reading plugins")
+	plugindirs, err := ioutil.ReadDir(b.path("plugins"))
+	b.must(err)
+	found := make(map[string]PluginDescr)
+	broken = make(map[string]struct{})
+	invalid := false
+	for _, plugindir := range plugindirs {
+		if !plugindir.IsDir() {
+			continue
+		}
+		p, err := b.discoverPlugin(plugindir.Name(), b.path("plugins", plugindir.Name()))
+		if err != nil {
+			if b.reportDiscoveryError(plugindir.Name(), err) {
+				broken[plugindir.Name()] = struct{}{}
+			}
+		} else {
+			p.importPath = "github.com/QuestScreen/QuestScreen/plugins/" + plugindir.Name()
+			if _, ok := found[plugindir.Name()]; ok {
+				b.logError("duplicate plugin id: " + plugindir.Name())
+				invalid = true
+			} else {
+				p.id = plugindir.Name()
+				found[plugindir.Name()] = p
+			}
+		}
+	}
+
+	for _, descr := range b.externalPlugins {
+		p, err := b.discoverPlugin(descr.importPath, descr.dir)
+		if err != nil {
+			if b.reportDiscoveryError(descr.importPath, err) {
+				broken[descr.id] = struct{}{}
+			}
+		} else {
+			if _, ok := found[descr.id]; ok {
+				b.logError("%s:%v: duplicate plugin ID `%v`", b.opts.PluginFile, descr.line, descr.id)
+				invalid = true
+			} else {
+				p.id = descr.id
+				p.version = descr.modVersion
+				found[descr.id] = p
+			}
+		}
+	}
+	ok = !invalid && len(broken) == 0
+	ok = b.checkRequirements(found, broken) && ok
+	ok = b.checkAPICompatibility(found) && ok
+	plugins, ordered := b.loadOrder(found)
+	if !ordered {
+		plugins = make([]PluginDescr, 0, len(found))
+		for _, id := range sortedIDs(found) {
+			plugins = append(plugins, found[id])
+		}
+	}
+	return plugins, broken, ok && ordered
+}
+
+// checkRequirements checks whether the plugins required by each plugin are
+// available in a matching version. Each problem is logged, except for missing
+// plugins that are broken.
+func (b *Builder) checkRequirements(plugins map[string]PluginDescr, broken map[string]struct{}) bool {
+	ok := true
+	for _, id := range sortedIDs(plugins) {
+		p := plugins[id]
+		for _, reqID := range sortedKeys(p.Requires) {
+			req, found := plugins[reqID]
+			if !found {
+				if _, isBroken := broken[reqID]; !isBroken {
+					b.logError("plugin `%s` requires plugin `%s` which is not available", id, reqID)
+				}
+				ok = false
+				continue
+			}
+			if p.Requires[reqID] == "" {
+				continue
+			}
+			// cannot fail since the manifest has been validated
+			constraint, _ := parseVersionConstraint(p.Requires[reqID])
+			if req.version == "" {
+				b.logWarning("plugin `%s` requires `%s %s`, but the version of `%s` is unknown",
+					id, reqID, constraint.String(), reqID)
+			} else if !constraint.allows(req.version) {
+				b.logError("plugin `%s` requires `%s %s`, but found %s",
+					id, reqID, constraint.String(), req.version)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+// compatibleAPIVersions checks whether code built against the api version
+// pluginVersion can be used with the api version hostVersion, following
+// semantic versioning: the major version must be equal, and for major version
+// zero, the minor version must also be equal.
+func compatibleAPIVersions(pluginVersion, hostVersion string) bool {
+	if semver.Major(pluginVersion) != semver.Major(hostVersion) {
+		return false
+	}
+	return semver.Major(hostVersion) != "v0" ||
+		semver.MajorMinor(pluginVersion) == semver.MajorMinor(hostVersion)
+}
+
+// checkAPICompatibility checks whether each plugin can be used with the
+// version of github.com/QuestScreen/api that QuestScreen requires. It checks
+// the api version range declared in the manifest and, if the plugin is a
+// module of its own, the api version the plugin's go.mod requires.
+// Each problem is logged.
+func (b *Builder) checkAPICompatibility(plugins map[string]PluginDescr) bool {
+	if b.apiVersion == "" {
+		b.logWarning("version of github.com/QuestScreen/api is unknown, skipping compatibility check")
+		return true
+	}
+	ok := true
+	for _, id := range sortedIDs(plugins) {
+		p := plugins[id]
+		if p.API != "" {
+			// cannot fail since the manifest has been validated
+			constraint, _ := parseVersionConstraint(p.API)
+			if !constraint.allows(b.apiVersion) {
+				b.logError("plugin `%s` supports github.com/QuestScreen/api `%s`, but QuestScreen uses %s",
+					id, constraint.String(), b.apiVersion)
+				ok = false
+			}
+		}
+
+		modPath := filepath.Join(p.dirPath, "go.mod")
+		content, err := ioutil.ReadFile(modPath)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				b.logError("plugin `%s`: %s", id, err.Error())
+				ok = false
+			}
+			continue
+		}
+		mod, err := modfile.ParseLax(modPath, content, nil)
+		if err != nil {
+			b.logError("plugin `%s`: %s", id, err.Error())
+			ok = false
+			continue
+		}
+		for _, r := range mod.Require {
+			if r.Mod.Path == "github.com/QuestScreen/api" {
+				if !compatibleAPIVersions(r.Mod.Version, b.apiVersion) {
+					b.logError("plugin `%s` has been built against github.com/QuestScreen/api %s, which is incompatible with %s used by QuestScreen",
+						id, r.Mod.Version, b.apiVersion)
+					ok = false
+				} else if semver.Compare(r.Mod.Version, b.apiVersion) > 0 {
+					b.logError("plugin `%s` requires github.com/QuestScreen/api %s, which is newer than %s used by QuestScreen",
+						id, r.Mod.Version, b.apiVersion)
+					ok = false
+				}
+				break
+			}
+		}
+	}
+	return ok
+}
+
+// loadOrder sorts the plugins so that each plugin comes after all plugins it
+// requires. Apart from that, plugins are ordered by ID.
+// Dependency cycles are logged as errors.
+func (b *Builder) loadOrder(plugins map[string]PluginDescr) ([]PluginDescr, bool) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(plugins))
+	ret := make([]PluginDescr, 0, len(plugins))
+	var stack []string
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		if _, ok := plugins[id]; !ok {
+			// reported by checkRequirements.
+			return true
+		}
+		switch state[id] {
+		case visited:
+			return true
+		case visiting:
+			i := 0
+			for stack[i] != id {
+				i++
+			}
+			b.logError("cyclic plugin dependency: %s -> %s",
+				strings.Join(stack[i:], " -> "), id)
+			return false
+		}
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, reqID := range sortedKeys(plugins[id].Requires) {
+			if !visit(reqID) {
+				return false
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+		ret = append(ret, plugins[id])
+		return true
+	}
+	for _, id := range sortedIDs(plugins) {
+		if !visit(id) {
+			return nil, false
+		}
+	}
+	return ret, true
+}
+
+func sortedIDs(plugins map[string]PluginDescr) []string {
+	ret := make([]string, 0, len(plugins))
+	for id := range plugins {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func sortedKeys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func process(input []PluginDescr) Data {
+	ret := make(Data, 0, len(input))
+	modCount := 0
+	for _, value := range input {
+		plugin := PluginData{ImportPath: value.importPath, ID: value.id, Name: value.Name,
+			DirPath: value.dirPath,
+			Modules: make([]ModuleData, len(value.Modules)), Templates: PluginTemplateData{
+				Groups:  make([]GroupTmplData, len(value.Templates.Groups)),
+				Scenes:  make([]SceneTmplData, 0, len(value.Templates.Scenes)),
+				Systems: make([]SystemTmplData, 0, len(value.Templates.Systems)),
+			}, Assets: value.assets}
+		for i, m := range value.Modules {
+			plugin.Modules[i] = ModuleData{ImportName: fmt.Sprintf("qmod%v", modCount), Name: m}
+			modCount++
+		}
+		for i, g := range value.Templates.Groups {
+			group := GroupTmplData{Name: g.Name, Description: g.Description,
+				Config: g.Config, Scenes: make([]SceneTmplRefData, len(g.Scenes))}
+			for j, s := range g.Scenes {
+				group.Scenes[j] = SceneTmplRefData{Name: s.Name, Template: s.Template}
+			}
+			plugin.Templates.Groups[i] = group
+		}
+		for _, id := range value.Templates.sceneOrder {
+			s := value.Templates.Scenes[id]
+			plugin.Templates.Scenes = append(plugin.Templates.Scenes,
+				SceneTmplData{
+					ID: id, Name: s.Name, Description: s.Description, Config: s.Config})
+		}
+		for _, id := range value.Templates.systemOrder {
+			s := value.Templates.Systems[id]
+			plugin.Templates.Systems = append(plugin.Templates.Systems,
+				SystemTmplData{
+					ID: id, Name: s.Name, Config: s.Config})
+		}
+		ret = append(ret, plugin)
+	}
+	return ret
+}
+
+// resolveSceneRef resolves a scene template reference of the form
+// `<plugin>.<scene>` to the index of the plugin in root and the index of the
+// scene template in that plugin.
+func resolveSceneRef(root Data, ref string) (pluginIndex, tmplIndex int, err error) {
+	dot := strings.IndexByte(ref, '.')
+	if dot < 0 {
+		return 0, 0, errors.New("cannot resolve scene template reference `" +
+			ref + "`: must have the form `<plugin>.<scene>`")
+	}
+	pluginID, sceneID := ref[:dot], ref[dot+1:]
+	for i, value := range root {
+		if value.ID == pluginID {
+			for j, scene := range value.Templates.Scenes {
+				if scene.ID == sceneID {
+					return i, j, nil
+				}
+			}
+			return 0, 0, errors.New("cannot resolve scene template reference `" +
+				ref + "`: unknown scene: " + sceneID)
+		}
+	}
+	return 0, 0, errors.New("cannot resolve scene template reference `" +
+		ref + "`: unknown plugin: " + pluginID)
+}
+
+// resolveSceneRefs resolves all scene template references in group templates
+// and stores the resulting indexes in the references.
+// Each unresolvable reference is logged, except for references to broken
+// plugins.
+func (b *Builder) resolveSceneRefs(root Data, broken map[string]struct{}) bool {
+	ok := true
+	for _, plugin := range root {
+		for _, group := range plugin.Templates.Groups {
+			for i := range group.Scenes {
+				ref := &group.Scenes[i]
+				var err error
+				ref.PluginIndex, ref.TmplIndex, err = resolveSceneRef(root, ref.Template)
+				if err != nil {
+					if _, isBroken := broken[strings.SplitN(ref.Template, ".", 2)[0]]; !isBroken {
+						b.logError("plugin `%s`, group template `%s`: %s", plugin.ID, group.Name, err.Error())
+					}
+					ok = false
+				}
+			}
+		}
+	}
+	return ok
+}
+
+var pluginsGoTmpl = template.Must(template.New("plugins.go").Funcs(template.FuncMap{
+	"yaml": func(value interface{}) (string, error) {
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	},
+}).Parse(`package plugins
+
+// Code generated by qs-build. DO NOT EDIT.
+
+import (
+	"github.com/QuestScreen/api/modules"
+	"github.com/QuestScreen/QuestScreen/app"
+	{{- range $value := .}}
+	{{- range $value.Modules}}
+	{{.ImportName}} "{{$value.ImportPath}}/{{.Name}}"
+	{{- end}}
+	{{- end}}
+)
+
+func LoadPlugins(a app.App) {
+	{{range . -}}
+	a.AddPlugin("{{.ID}}", &app.Plugin{
+		Name: "{{js .Name}}",
+		Modules: []*modules.Module{
+			{{- range .Modules}}
+			&{{.ImportName}}.Descriptor,
+			{{- end}}
+		},
+		SystemTemplates: []app.SystemTemplate{
+			{{- range .Templates.Systems}}
+			{
+				ID: "{{js .ID}}",
+				Name: "{{js .Name}}",
+				Config: []byte(` + "`" + `{{yaml .Config}}` + "`" + `),
+			},
+			{{- end}}
+		},
+		GroupTemplates: []app.GroupTemplate{
+			{{- range .Templates.Groups}}
+			{
+				Name: "{{js .Name}}",
+				Description: "{{js .Description}}",
+				Config: []byte(` + "`" + `{{yaml .Config}}` + "`" + `),
+				Scenes: []app.SceneTmplRef{
+					{{- range .Scenes}}
+					{
+						Name: "{{js .Name}}",
+						PluginIndex: {{.PluginIndex}},
+						TmplIndex: {{.TmplIndex}},
+					},
+					{{- end}}
+				},
+			},
+			{{- end}}
+		},
+		SceneTemplates: []app.SceneTemplate{
+			{{- range .Templates.Scenes}}
+			{
+				Name: "{{js .Name}}",
+				Description: "{{js .Description}}",
+				Config: []byte(` + "`" + `{{yaml .Config}}` + "`" + `),
+			},
+			{{- end}}
+		},
+	})
+	{{end}}
+}
+`))
+
+func (b *Builder) ensureFileDoesntExistOrIsAutogenerated(path string) string {
+	abs, err := filepath.Abs(path)
+	b.must(err, "unable to transform path to absolute path:")
+	b.logInfo("generating " + abs)
+
+	data, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return abs
+		}
+		b.must(err, "unable to stat file:")
+	}
+	b.mustCond(data.Mode().IsRegular(),
+		fmt.Sprintf("%v: already exists and not a regular file", abs))
+	file, err := os.Open(abs)
+	b.must(err, "unable to open file:")
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	generated := false
+	for line, err := reader.ReadString('\n'); err == nil; line, err = reader.ReadString('\n') {
+		if line == "// Code generated by qs-build. DO NOT EDIT.\n" {
+			generated = true
+			break
+		}
+	}
+
+	if err != nil && err != io.EOF {
+		b.must(err, "failed to read from existing file:")
+	}
+	b.mustCond(generated, abs+":", "… refusing to overwrite file that was not generated by qs-build. delete manually.")
+	return abs
+}
+
+func (b *Builder) writePluginCollector(plugins Data) error {
+	path := b.ensureFileDoesntExistOrIsAutogenerated(b.path("plugins", "plugins.go"))
+	var writer strings.Builder
+	if err := pluginsGoTmpl.Execute(&writer, plugins); err != nil {
+		return err
+	}
+	b.writeFormatted(writer.String(), path)
+
+	yamlFile, err := os.Create(b.path("plugins", "plugins.yaml"))
+	if err != nil {
+		return err
+	}
+	defer yamlFile.Close()
+	encoder := yaml.NewEncoder(yamlFile)
+	defer encoder.Close()
+	return encoder.Encode(plugins)
+}
+
+var webPluginsLoaderTmpl = template.Must(template.New("webPluginsLoader").Funcs(
+	template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+	}).Parse(
+	`package main
+
+// Code generated by qs-build. DO NOT EDIT.
+
+import (
+	"github.com/QuestScreen/QuestScreen/web"
+	{{- range $p := .}}
+	{{- range $p.Modules}}
+	{{.ImportName}} "{{$p.ImportPath}}/web/{{.Name}}"
+	{{- end}}
+	{{- end}}
+)
+
+func registerPlugins(loader *pluginLoader) error {
+	{{- range $index, $value := .}}
+	loader.id = "{{$value.ID}}"
+	loader.index = {{$index}}
+	{{- range $value.Modules}}
+	if err := loader.RegisterModule("{{.Name}}", {{.ImportName}}.NewState, web.ConfigDescriptor{{.ImportName}});
+		err != nil {
+		return err
+	}
+	{{- end}}
+	{{- end}}
+	return nil
+}
+`))
+
+func (b *Builder) writeWebPluginLoader(plugins Data) {
+	path := b.ensureFileDoesntExistOrIsAutogenerated(b.path("web", "main", "plugins.go"))
+	var loader strings.Builder
+	b.must(webPluginsLoaderTmpl.Execute(&loader, plugins),
+		"failed to render plugins.go:")
+	b.writeFormatted(loader.String(), path)
+}
+
+var configLoaderGeneratorTmpl = template.Must(template.New("configLoaderGenerator").Parse(
+	`package main
+
+// Code generated by qs-build. DO NOT EDIT.
+
+import (
+	"github.com/QuestScreen/qs-build/inspector"
+	{{- range .}}
+	{{.ID}} "{{.Import}}"
+	{{- end}}
+)
+
+func main() {
+	err := inspector.InspectConfigs(
+		{{- range .}}
+		inspector.Module{Name: "{{.Name}}", ID: "{{.ID}}", DefaultConfig: {{.ID}}.Descriptor.DefaultConfig},
+		{{- end}}
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+`))
+
+// inspectedModule is a module whose configuration is inspected by the
+// inspector program.
+type inspectedModule struct {
+	Import, Name, ID string
+}
+
+const qsBuildModule = "github.com/QuestScreen/qs-build"
+
+// requireInspector makes the inspector package available in the session's
+// QuestScreen module. The generated inspector program uses the API of the
+// running qs-build, so at least its version is required even if QuestScreen
+// pins an older one. Development builds have no version and use the pinned
+// one, which may be replaced by a local qs-build source; without a pin, their
+// API might not match any released version, so inspection fails.
+func (b *Builder) requireInspector() {
+	pinned, hasPinned := b.buildList()[qsBuildModule]
+	version := ""
+	if info, ok := debug.ReadBuildInfo(); ok && semver.IsValid(info.Main.Version) &&
+		semver.Build(info.Main.Version) == "" {
+		version = info.Main.Version
+	}
+	switch {
+	case version == "" && hasPinned:
+		b.logWarning("unknown version of qs-build, using %s@%s for the inspector",
+			qsBuildModule, pinned)
+		return
+	case version == "":
+		b.logError("qs-build is a development build, whose inspector for module configurations is not available")
+		b.logError("require %s in QuestScreen's go.mod and replace it with the qs-build source directory,", qsBuildModule)
+		b.logError("or install a released version of qs-build.")
+		b.fail()
+	case hasPinned && semver.Compare(pinned, version) >= 0:
+		return
+	}
+	b.runAndCheck(b.goModCmd("get", qsBuildModule+"@"+version),
+		func(err error, stderr string) {
+			b.logError("failed to load inspector for module configuration:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+}
+
+// executeInspector builds a single program inside the QuestScreen module that
+// inspects the configurations of all given modules via reflection, and writes
+// the config loader of each module.
+// It returns false if the configuration of any module cannot be mapped.
+func (b *Builder) executeInspector(modules []inspectedModule) bool {
+	b.requireInspector()
+
+	var dirPath string
+	for i := 0; ; i++ {
+		if i == 0 {
+			dirPath = b.path("tmp")
+		} else {
+			dirPath = b.path(fmt.Sprintf("tmp%v", i))
+		}
+		if _, err := os.Stat(dirPath); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			b.must(err)
+		}
+	}
+	b.must(os.Mkdir(dirPath, 0755))
+	defer b.onCleanup(func() {
+		os.RemoveAll(dirPath)
+	})()
+
+	inspectorExe, err := os.Create(filepath.Join(dirPath, "main.go"))
+	b.must(err, "failed to create file in temporary directory:")
+	if err = configLoaderGeneratorTmpl.Execute(inspectorExe, modules); err != nil {
+		inspectorExe.Close()
+		b.must(err, "failed to generate content for config loader generator:")
+	}
+	inspectorExe.Close()
+
+	var mainName string
+	if runtime.GOOS == "windows" {
+		mainName = "main.exe"
+	} else {
+		mainName = "main"
+	}
+
+	buildCmd := b.goModCmd("build", "-o", mainName)
+	buildCmd.Dir = dirPath
+	b.runAndDumpIfVerbose(buildCmd,
+		func(err error, stderr string) {
+			b.logError(fmt.Sprintf("[tmpdir: %v]:", dirPath))
+			b.logError("failed to build inspector for module configuration:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(filepath.Join(dirPath, mainName))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = b.runTracked(cmd); err != nil {
+		b.logError(fmt.Sprintf("[tmpdir: %v]:", dirPath))
+		b.logError("failed to execute inspector for module configuration:")
+		b.logError(err.Error())
+		b.writeErrorLines(stderr.String())
+		b.fail()
+	}
+
+	var results []inspector.Result
+	b.must(json.Unmarshal(stdout.Bytes(), &results),
+		"failed to read output of inspector for module configuration:")
+	ok := true
+	for _, result := range results {
+		if result.Error != "" {
+			b.logError(result.Error)
+			ok = false
+			continue
+		}
+		b.writeFormatted(result.Code, b.ensureFileDoesntExistOrIsAutogenerated(
+			b.path("web", "configitems"+result.ID+".go")))
+	}
+	return ok
+}
+
+// writeModuleConfigLoaders generates the config loader of each module.
+// The packages of all modules are loaded at once and their configuration types
+// are determined statically if possible, inspecting up to b.opts.Jobs modules
+// in parallel; all other modules are inspected by a
+// single inspector program via reflection. Errors are reported for all
+// modules before failing.
+func (b *Builder) writeModuleConfigLoaders(plugins Data) {
+	var modules []inspectedModule
+	for _, plugin := range plugins {
+		for _, module := range plugin.Modules {
+			modules = append(modules, inspectedModule{
+				Import: plugin.ImportPath + "/" + module.Name,
+				Name:   module.Name, ID: module.ImportName})
+		}
+	}
+	importPaths := make([]string, len(modules))
+	for i := range modules {
+		importPaths[i] = modules[i].Import
+	}
+	pkgs, loadErr := b.loadModulePackages(importPaths)
+	codes := make([]string, len(modules))
+	errs := make([]error, len(modules))
+	b.parallel(len(modules), func(i int) {
+		if pkg, ok := pkgs[modules[i].Import]; ok {
+			codes[i], errs[i] = b.inspectConfigStatically(pkg, modules[i].Name, modules[i].ID)
+		} else if loadErr != nil {
+			errs[i] = loadErr
+		} else {
+			errs[i] = notStaticError{"failed to load package " + modules[i].Import}
+		}
+	})
+
+	ok := true
+	var remaining []inspectedModule
+	for i, module := range modules {
+		switch errs[i].(type) {
+		case nil:
+			b.writeFormatted(codes[i], b.ensureFileDoesntExistOrIsAutogenerated(
+				b.path("web", "configitems"+module.ID+".go")))
+		case notStaticError:
+			if b.opts.Verbose {
+				b.logVerbose(fmt.Sprintf("%v: cannot inspect configuration statically (%v)",
+					module.Import, errs[i].Error()))
+			}
+			remaining = append(remaining, module)
+		default:
+			b.logError(errs[i].Error())
+			ok = false
+		}
+	}
+	if len(remaining) > 0 {
+		b.logInfo(fmt.Sprintf("building inspector for %v module configurations", len(remaining)))
+		if !b.executeInspector(remaining) {
+			ok = false
+		}
+	}
+	b.mustCond(ok, "failed to inspect module configurations")
+}
+
+// discoverAndProcess discovers all plugins and resolves their scene template
+// references.
+func (b *Builder) discoverAndProcess() Data {
+	discovered, _, ok := b.discoverPlugins()
+	b.mustCond(ok, "failed to load plugins")
+	plugins := process(discovered)
+	b.mustCond(b.resolveSceneRefs(plugins, nil), "failed to resolve scene template references")
+	return plugins
+}
+
+// pluginsInputs returns the inputs of writePluginLoaders. The generated files
+// reside in the plugins and web directories.
+func (b *Builder) pluginsInputs() phaseInputs {
+	in := phaseInputs{paths: []string{"go.mod", "go.sum", b.goCmd, "plugins"},
+		generated: []string{filepath.Join("plugins", "plugins.yaml"),
+			filepath.Join("web", "main", "plugins.go"),
+			filepath.Join("web", "configitems*.go")}}
+	if b.opts.PluginFile != "" {
+		// the phase writes plugins.lock if it is missing or to be updated.
+		in.params = append(in.params, strconv.FormatBool(b.opts.UpdateLock))
+		in.paths = append(in.paths, b.opts.PluginFile)
+		in.outputs = append(in.outputs,
+			filepath.Join(filepath.Dir(b.opts.PluginFile), "plugins.lock"))
+	}
+	for _, p := range b.externalPlugins {
+		in.paths = append(in.paths, p.dir)
+	}
+	return in
+}
+
+func (b *Builder) writePluginLoaders() {
+	plugins := b.discoverAndProcess()
+	b.writePendingLock()
+	for _, plugin := range plugins {
+		for i := range plugin.Templates.Systems {
+			value := &plugin.Templates.Systems[i]
+			if value.Config == nil {
+				value.Config = make(map[string]interface{})
+			}
+			value.Config["name"] = value.Name
+		}
+	}
+
+	b.must(b.writePluginCollector(plugins))
+	b.writeModuleConfigLoaders(plugins)
+	b.writeWebPluginLoader(plugins)
+}
+
+func (b *Builder) validatePlugins() {
+	// all problems are reported, including the scene template references of
+	// plugins whose manifest is valid if other plugins are broken.
+	discovered, broken, ok := b.discoverPlugins()
+	ok = b.resolveSceneRefs(process(discovered), broken) && ok
+	b.mustCond(ok, "plugin validation failed")
+	b.logInfo(fmt.Sprintf("all %v plugins are valid", len(discovered)))
+}
@@ -0,0 +1,64 @@
+package qsbuild
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		requires map[string][]string
+		order    []string
+		err      string
+	}{
+		{"no dependencies", map[string][]string{"c": nil, "a": nil, "b": nil},
+			[]string{"a", "b", "c"}, ""},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			[]string{"c", "b", "a"}, ""},
+		{"diamond", map[string][]string{"a": {"c", "b"}, "b": {"d"}, "c": {"d"}, "d": nil},
+			[]string{"d", "b", "c", "a"}, ""},
+		{"independent after dependencies", map[string][]string{"a": {"z"}, "b": nil, "z": nil},
+			[]string{"z", "a", "b"}, ""},
+		// missing plugins are reported by checkRequirements.
+		{"missing requirement", map[string][]string{"a": {"missing"}, "b": nil},
+			[]string{"a", "b"}, ""},
+		{"cycle", map[string][]string{"a": {"b"}, "b": {"a"}}, nil,
+			"cyclic plugin dependency: a -> b -> a"},
+		{"self", map[string][]string{"a": {"a"}}, nil,
+			"cyclic plugin dependency: a -> a"},
+		{"cycle below", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}}, nil,
+			"cyclic plugin dependency: b -> c -> d -> b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plugins := make(map[string]PluginDescr, len(tc.requires))
+			for id, reqs := range tc.requires {
+				p := PluginDescr{id: id, Requires: make(map[string]string)}
+				for _, req := range reqs {
+					p.Requires[req] = ""
+				}
+				plugins[id] = p
+			}
+			var log strings.Builder
+			b := New(Options{Logger: recordingLogger{testLogger{t}, &log, false}})
+			ordered, ok := b.loadOrder(plugins)
+			if tc.err != "" {
+				if ok || strings.TrimSpace(log.String()) != tc.err {
+					t.Errorf("expected error `%s`, got `%s`", tc.err, log.String())
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("unexpected errors:\n%s", log.String())
+			}
+			ids := make([]string, len(ordered))
+			for i, p := range ordered {
+				ids[i] = p.id
+			}
+			if !reflect.DeepEqual(ids, tc.order) {
+				t.Errorf("expected order %v, got %v", tc.order, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package qsbuild
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// pluginsFileError describes a problem in a line of the plugins file.
+type pluginsFileError struct {
+	path string
+	line int
+	msg  string
+}
+
+func (e pluginsFileError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.path, e.line, e.msg)
+}
+
+// parsePluginsLine parses a single line of the plugins file.
+// The syntax of a line is
+//
+//	[id] importPath[@version] [h1:hash] [=> localPath]
+//
+// Everything after a `#` is a comment. The returned bool is false for lines
+// that do not contain a plugin.
+func parsePluginsLine(line string) (pluginDescr, bool, error) {
+	var descr pluginDescr
+	if idx := strings.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
+	}
+	if idx := strings.Index(line, "=>"); idx >= 0 {
+		local := strings.Fields(line[idx+2:])
+		switch len(local) {
+		case 0:
+			return descr, false, fmt.Errorf("missing local path after `=>`")
+		case 1:
+			descr.localPath = local[0]
+		default:
+			return descr, false, fmt.Errorf("too many items after `=>`")
+		}
+		line = line[:idx]
+	}
+	items := strings.Fields(line)
+	if len(items) == 0 {
+		if descr.localPath != "" {
+			return descr, false, fmt.Errorf("missing import path before `=>`")
+		}
+		return descr, false, nil
+	}
+	if last := items[len(items)-1]; strings.HasPrefix(last, "h1:") {
+		descr.sum = last
+		items = items[:len(items)-1]
+	}
+	switch len(items) {
+	case 0:
+		return descr, false, fmt.Errorf("missing import path before `%s`", descr.sum)
+	case 1:
+		descr.importPath = items[0]
+	case 2:
+		descr.id, descr.importPath = items[0], items[1]
+	default:
+		return descr, false, fmt.Errorf("too many items, expected `[id] importPath[@version] [h1:hash] [=> localPath]`")
+	}
+	if idx := strings.IndexByte(descr.importPath, '@'); idx >= 0 {
+		descr.version = descr.importPath[idx+1:]
+		descr.importPath = descr.importPath[:idx]
+		if descr.version == "" {
+			return descr, false, fmt.Errorf("missing version after `@`")
+		}
+	}
+	if err := module.CheckImportPath(descr.importPath); err != nil {
+		return descr, false, err
+	}
+	if descr.id == "" {
+		descr.id = filepath.Base(descr.importPath)
+	}
+	if descr.localPath != "" {
+		if descr.version != "" {
+			return descr, false, fmt.Errorf("cannot give a version for a plugin with local path")
+		}
+		if descr.sum != "" {
+			return descr, false, fmt.Errorf("cannot pin the hash of a plugin with local path")
+		}
+	}
+	return descr, true, nil
+}
+
+// parsePluginsFile reads all plugins given in the plugins file at path.
+// It returns every problem found in the file.
+func parsePluginsFile(path string) ([]pluginDescr, []error) {
+	pFile, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer pFile.Close()
+	var ret []pluginDescr
+	var errs []error
+	lines := make(map[string]int)
+	scanner := bufio.NewScanner(pFile)
+	for lineCount := 1; scanner.Scan(); lineCount++ {
+		descr, ok, err := parsePluginsLine(scanner.Text())
+		if err != nil {
+			errs = append(errs, pluginsFileError{path, lineCount, err.Error()})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if prev, ok := lines[descr.id]; ok {
+			errs = append(errs, pluginsFileError{path, lineCount,
+				fmt.Sprintf("duplicate plugin ID `%s` (previously given in line %d)",
+					descr.id, prev)})
+			continue
+		}
+		lines[descr.id] = lineCount
+		descr.line = lineCount
+		if descr.localPath != "" && !filepath.IsAbs(descr.localPath) {
+			descr.localPath = filepath.Join(filepath.Dir(path), descr.localPath)
+		}
+		ret = append(ret, descr)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return ret, errs
+}
+
+// moduleSum returns the hash the session's go.sum records for the given
+// module version.
+func (b *Builder) moduleSum(importPath, version string) string {
+	content, err := ioutil.ReadFile(filepath.Join(b.sessionModDir, "go.sum"))
+	b.must(err, "failed to read go.sum:")
+	for _, line := range strings.Split(string(content), "\n") {
+		items := strings.Fields(line)
+		if len(items) == 3 && items[0] == importPath && items[1] == version {
+			return items[2]
+		}
+	}
+	return ""
+}
+
+// resolvePlugin makes the plugin available in the session's copy of the
+// QuestScreen module and queries its version and directory.
+// If locked is true, the plugin's version has already been loaded from
+// plugins.lock and is not updated.
+func (b *Builder) resolvePlugin(descr *pluginDescr, locked bool) {
+	errorHandler := func(err error, stderr string) {
+		b.logError("%s:%d: failed to load plugin `%s`:", b.opts.PluginFile, descr.line, descr.id)
+		b.logError(err.Error())
+		b.writeErrorLines(stderr)
+	}
+	if descr.localPath != "" {
+		var err error
+		descr.dir, err = filepath.Abs(descr.localPath)
+		b.must(err)
+		b.logInfo(fmt.Sprintf("loading plugin '%s' from \"%s\"", descr.id, descr.dir))
+		content, err := ioutil.ReadFile(filepath.Join(descr.dir, "go.mod"))
+		b.must(err, fmt.Sprintf("%s:%d: %s does not contain a Go module:",
+			b.opts.PluginFile, descr.line, descr.dir))
+		modPath := modfile.ModulePath(content)
+		b.mustCond(modPath == descr.importPath, fmt.Sprintf(
+			"%s:%d: %s contains module `%s`, expected `%s`", b.opts.PluginFile,
+			descr.line, descr.dir, modPath, descr.importPath))
+		b.runAndCheck(b.goModCmd("mod", "edit",
+			"-replace", descr.importPath+"="+descr.dir,
+			"-require", descr.importPath+"@v0.0.0-00010101000000-000000000000"), errorHandler)
+		return
+	}
+
+	var getPath string
+	if descr.version == "" {
+		getPath = descr.importPath
+	} else {
+		getPath = fmt.Sprintf("%s@%s", descr.importPath, descr.version)
+	}
+	if locked {
+		b.logInfo(fmt.Sprintf("loading plugin '%s' at \"%s\" (locked)", descr.id, getPath))
+	} else {
+		b.logInfo(fmt.Sprintf("loading plugin '%s' at \"%s\"", descr.id, getPath))
+		b.runAndCheck(b.goModCmd("get", "-u", getPath), errorHandler)
+	}
+
+	modInfo := b.runAndCheck(b.goModCmd("list", "-m", "-f", "{{.Version}} {{.Dir}}", descr.importPath), errorHandler)
+	if idx := strings.IndexByte(modInfo, ' '); idx >= 0 {
+		descr.modVersion, descr.dir = modInfo[:idx], modInfo[idx+1:]
+	} else {
+		descr.dir = modInfo
+	}
+
+	if descr.sum != "" {
+		actual := b.moduleSum(descr.importPath, descr.modVersion)
+		b.mustCond(actual == descr.sum, fmt.Sprintf(
+			"%s:%d: checksum mismatch for plugin `%s` at %s:", b.opts.PluginFile,
+			descr.line, descr.id, descr.modVersion),
+			"… expected: "+descr.sum, "… go.sum:   "+actual)
+	}
+}
+
+// loadPluginsFile parses the plugins file and loads all plugins given there.
+// If plugins.lock does not exist or is to be updated, a new lock is created
+// and left for writePendingLock.
+func (b *Builder) loadPluginsFile(path string) {
+	plugins, errs := parsePluginsFile(path)
+	for _, err := range errs {
+		b.logError(err.Error())
+	}
+	b.mustCond(len(errs) == 0, "failed to read plugins file")
+
+	lockPath := filepath.Join(filepath.Dir(path), "plugins.lock")
+	var lock *pluginsLock
+	if !b.opts.UpdateLock {
+		lock, errs = readPluginsLock(lockPath)
+		for _, err := range errs {
+			b.logError(err.Error())
+		}
+		b.mustCond(len(errs) == 0, "failed to read "+lockPath)
+	}
+	var before map[string]string
+	if lock == nil {
+		before = b.buildList()
+	} else {
+		mismatches := lock.check(plugins)
+		for _, m := range mismatches {
+			b.logError(lockPath + ": " + m)
+		}
+		b.mustCond(len(mismatches) == 0, lockPath+" does not match "+path,
+			"run `qs-build plugins update` to update it.")
+		b.logInfo("loading module versions from " + lockPath)
+		lock.apply(b)
+	}
+
+	for i := range plugins {
+		b.resolvePlugin(&plugins[i], lock != nil)
+	}
+	after := b.buildList()
+	if lock == nil {
+		b.pendingLock, b.pendingLockPath = b.createPluginsLock(plugins, before, after), lockPath
+	}
+	// the plugins may have upgraded github.com/QuestScreen/api, which they are
+	// checked against by checkAPICompatibility.
+	if v := after["github.com/QuestScreen/api"]; v != "" {
+		b.apiVersion = v
+	}
+	b.externalPlugins = append(b.externalPlugins, plugins...)
+}
+
+// writePendingLock writes the plugins.lock created by loadPluginsFile, if any.
+func (b *Builder) writePendingLock() {
+	if b.pendingLock == nil {
+		return
+	}
+	b.logInfo("writing " + b.pendingLockPath)
+	b.must(b.pendingLock.write(b.pendingLockPath), "failed to write "+b.pendingLockPath+":")
+	b.pendingLock = nil
+}
@@ -0,0 +1,80 @@
+package qsbuild
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePluginsLine(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		descr pluginDescr
+		ok    bool
+		err   string
+	}{
+		{line: "", ok: false},
+		{line: "   # comment", ok: false},
+		{line: "example.com/plugins/dice", ok: true,
+			descr: pluginDescr{id: "dice", importPath: "example.com/plugins/dice"}},
+		{line: "d6 example.com/dice@v1.2.0 # six sides", ok: true,
+			descr: pluginDescr{id: "d6", importPath: "example.com/dice", version: "v1.2.0"}},
+		{line: "example.com/dice@v1.2.0 h1:abc=", ok: true,
+			descr: pluginDescr{id: "dice", importPath: "example.com/dice", version: "v1.2.0", sum: "h1:abc="}},
+		{line: "local example.com/dice => ../dice", ok: true,
+			descr: pluginDescr{id: "local", importPath: "example.com/dice", localPath: "../dice"}},
+		{line: "example.com/dice =>", err: "missing local path after `=>`"},
+		{line: "example.com/dice => a b", err: "too many items after `=>`"},
+		{line: "=> ../dice", err: "missing import path before `=>`"},
+		{line: "h1:abc=", err: "missing import path before `h1:abc=`"},
+		{line: "a b c", err: "too many items, expected `[id] importPath[@version] [h1:hash] [=> localPath]`"},
+		{line: "example.com/dice@", err: "missing version after `@`"},
+		{line: "example.com/dice@v1 => ../dice", err: "cannot give a version for a plugin with local path"},
+		{line: "example.com/dice h1:abc= => ../dice", err: "cannot pin the hash of a plugin with local path"},
+		{line: "example.com/../dice", err: `malformed import path "example.com/../dice": invalid path element ".."`},
+	} {
+		t.Run(tc.line, func(t *testing.T) {
+			descr, ok, err := parsePluginsLine(tc.line)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error `%s`, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tc.ok || descr != tc.descr {
+				t.Errorf("expected %+v (%v), got %+v (%v)", tc.descr, tc.ok, descr, ok)
+			}
+		})
+	}
+}
+
+func TestParsePluginsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugins.txt")
+	writeTestFiles(t, dir, map[string]string{"plugins.txt": `# plugins
+example.com/dice
+other example.com/dice => ../dice
+example.com/a/dice
+a b c
+`})
+	plugins, errs := parsePluginsFile(path)
+	expected := []pluginDescr{
+		{id: "dice", importPath: "example.com/dice", line: 2},
+		{id: "other", importPath: "example.com/dice", line: 3,
+			localPath: filepath.Join(dir, "..", "dice")},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v, got %+v", expected, plugins)
+	}
+	expectedErrs := []string{
+		path + ":4: duplicate plugin ID `dice` (previously given in line 2)",
+		path + ":5: too many items, expected `[id] importPath[@version] [h1:hash] [=> localPath]`",
+	}
+	if fmt.Sprint(errs) != fmt.Sprint(expectedErrs) {
+		t.Errorf("expected errors %v, got %v", expectedErrs, errs)
+	}
+}
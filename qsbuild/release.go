@@ -0,0 +1,56 @@
+package qsbuild
+
+import (
+	"strings"
+)
+
+// ReleaseKind selects what Release creates.
+type ReleaseKind int
+
+const (
+	// ReleaseSource creates a source archive including the version info.
+	ReleaseSource ReleaseKind = iota
+	// ReleaseWindowsBinary builds QuestScreen and packages it together with the
+	// required DLLs. Only available on Windows.
+	ReleaseWindowsBinary
+)
+
+func (b *Builder) release(kind ReleaseKind) {
+	b.mustCond(b.isWorkingDir(), "cannot release: not in working directory")
+	b.mustCond(!b.opts.DevAssets, "cannot release with dev assets:",
+		"… the binary would load its assets from the local assets directory")
+
+	res := b.runAndCheck(b.command("git", "status", "--porcelain"),
+		func(err error, stderr string) {
+			b.logError("failed to check working directory git status:")
+			b.logError(err.Error())
+		})
+	if strings.TrimSpace(res) != "" {
+		b.logError("working directory not clean!")
+		b.logError("please commit uncommited changes and remove untracked files before building a release.")
+		b.fail()
+	}
+
+	relname := "questscreen-" + b.writeVersionInfo()
+
+	switch kind {
+	case ReleaseSource:
+		b.releaseSource(relname)
+	case ReleaseWindowsBinary:
+		b.releaseWindowsBinary(relname)
+	}
+}
+
+func (b *Builder) releaseSource(relname string) {
+
+	archive := b.command("git", "archive", "master", "-o", "tmparchive.tar")
+	b.must(b.runTracked(archive))
+	tar := b.command("tar", "--append", "-f", "tmparchive.tar", "versioninfo/versioninfo.go")
+	b.must(b.runTracked(tar))
+
+	xz := b.command("xz", "-z", "tmparchive.tar")
+	b.must(b.runTracked(xz))
+	b.checkRename(b.path("tmparchive.tar.xz"), b.path(relname+".tar.xz"))
+
+	b.logInfo("created release archive")
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package qsbuild
+
+func (b *Builder) releaseWindowsBinary(relname string) {
+	b.logError("you must be on Windows to build a Windows binary release")
+	b.fail()
+}
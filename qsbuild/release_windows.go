@@ -0,0 +1,85 @@
+//go:build windows
+// +build windows
+
+package qsbuild
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func (b *Builder) findDll(name string) string {
+	path := b.runAndCheck(exec.Command("where", name+".dll"), func(err error, stderr string) {
+		b.logError("unable to locate " + name + ":")
+		b.logError(err.Error())
+	})
+	return strings.TrimSpace(path)
+}
+
+func (b *Builder) releaseWindowsBinary(relname string) {
+	if err := b.Build(); err != nil {
+		b.fail()
+	}
+	b.logPhase("Release")
+
+	b.logInfo("finding DLLs")
+	libs := [9]string{
+		b.findDll("SDL2"),
+		b.findDll("SDL2_image"),
+		b.findDll("libjpeg-9"),
+		b.findDll("libpng16-16"),
+		b.findDll("libtiff-5"),
+		b.findDll("libwebp-7"),
+		b.findDll("zlib1"),
+		b.findDll("SDL2_ttf"),
+		b.findDll("libfreetype-6"),
+	}
+
+	b.logInfo("creating " + relname + ".zip")
+	relzip, err := os.Create(b.path(relname + ".zip"))
+	b.must(err)
+	defer relzip.Close()
+
+	w := zip.NewWriter(relzip)
+	defer w.Close()
+
+	addFiles := func(base, root string) {
+		b.must(filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			inclPath, err := filepath.Rel(base, path)
+			b.must(err)
+
+			zf, err := w.Create(filepath.Join(relname, inclPath))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(zf, file)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		}))
+	}
+	for _, lib := range libs {
+		addFiles(filepath.Dir(lib), lib)
+	}
+	addFiles(b.root, b.path("questscreen.exe"))
+	addFiles(b.root, b.path("resources"))
+}
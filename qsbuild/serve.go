@@ -0,0 +1,98 @@
+package qsbuild
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// contentTypes overrides the MIME types of files served by Serve whose type
+// is not reliably known to the system's MIME database. Browsers refuse to
+// compile WebAssembly streamed with any other type.
+var contentTypes = map[string]string{
+	".wasm": "application/wasm",
+	".js":   "text/javascript; charset=utf-8",
+	".map":  "application/json",
+}
+
+// Serve serves the web UI from assets/ on the given address, e.g.
+// "localhost:8080". Files are read on each request and never cached by the
+// browser, so that changes are visible after a reload. Pre-compressed variants
+// written by Options.Optimize are sent to clients that accept them. Serve
+// blocks until the Builder is closed.
+func (b *Builder) Serve(addr string) error {
+	var server *http.Server
+	var listener net.Listener
+	err := b.phase("Serve", func() {
+		var err error
+		listener, err = net.Listen("tcp", addr)
+		b.must(err, "failed to listen on "+addr+":")
+		files := http.FileServer(http.Dir(b.path("assets")))
+		server = &http.Server{Handler: http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if b.opts.Verbose {
+					b.logVerbose(r.Method + " " + r.URL.Path)
+				}
+				name := path.Clean("/" + r.URL.Path)
+				ext := path.Ext(name)
+				if t, ok := contentTypes[ext]; ok {
+					w.Header().Set("Content-Type", t)
+				} else if t = mime.TypeByExtension(ext); t != "" {
+					w.Header().Set("Content-Type", t)
+				}
+				w.Header().Set("Cache-Control", "no-store")
+				w.Header().Add("Vary", "Accept-Encoding")
+				for _, enc := range assetEncodings {
+					if !acceptsEncoding(r.Header.Get("Accept-Encoding"), enc.name) {
+						continue
+					}
+					info, err := os.Stat(b.path("assets", filepath.FromSlash(name+enc.ext)))
+					if err == nil && !info.IsDir() {
+						w.Header().Set("Content-Encoding", enc.name)
+						encoded := r.Clone(r.Context())
+						encoded.URL.Path = name + enc.ext
+						r = encoded
+						break
+					}
+				}
+				files.ServeHTTP(w, r)
+			})}
+		b.onCleanup(func() {
+			server.Close()
+		})
+		b.logInfo("serving " + b.path("assets") + " at http://" + listener.Addr().String())
+	})
+	if err != nil {
+		return err
+	}
+	if err = server.Serve(listener); err != http.ErrServerClosed {
+		b.logError("server failed: %s", err.Error())
+		return &PhaseError{Phase: "Serve", Msg: err.Error()}
+	}
+	return nil
+}
+
+// acceptsEncoding checks whether the given Accept-Encoding header value allows
+// the given content encoding.
+func acceptsEncoding(header, encoding string) bool {
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		if strings.TrimSpace(parts[0]) != encoding {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package qsbuild
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+type versionComparison struct {
+	op, version string
+}
+
+// versionConstraint is a list of comparisons that must all hold for a version
+// to satisfy the constraint. Its textual form is a comma-separated list like
+// `>= v1.2.0, < v2.0.0`. A version without an operator is a minimum version.
+type versionConstraint []versionComparison
+
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var ret versionConstraint
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, errors.New("empty comparison in version constraint `" + s + "`")
+		}
+		cmp := versionComparison{op: ">="}
+		for _, op := range versionOperators {
+			if strings.HasPrefix(item, op) {
+				cmp.op = op
+				item = strings.TrimSpace(item[len(op):])
+				break
+			}
+		}
+		if !strings.HasPrefix(item, "v") {
+			item = "v" + item
+		}
+		if !semver.IsValid(item) {
+			return nil, errors.New("invalid semantic version `" + item +
+				"` in version constraint `" + s + "`")
+		}
+		cmp.version = item
+		ret = append(ret, cmp)
+	}
+	return ret, nil
+}
+
+// allows checks whether the given semantic version satisfies c.
+func (c versionConstraint) allows(version string) bool {
+	for _, cmp := range c {
+		res := semver.Compare(version, cmp.version)
+		var ok bool
+		switch cmp.op {
+		case ">=":
+			ok = res >= 0
+		case "<=":
+			ok = res <= 0
+		case "!=":
+			ok = res != 0
+		case ">":
+			ok = res > 0
+		case "<":
+			ok = res < 0
+		case "=":
+			ok = res == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c versionConstraint) String() string {
+	items := make([]string, len(c))
+	for i, cmp := range c {
+		items[i] = cmp.op + " " + cmp.version
+	}
+	return strings.Join(items, ", ")
+}
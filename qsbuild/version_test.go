@@ -0,0 +1,54 @@
+package qsbuild
+
+import "testing"
+
+func TestParseVersionConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint, normalized string
+		allowed, rejected      []string
+	}{
+		{"1.2", ">= v1.2", []string{"v1.2.0", "v1.3.0", "v2.0.0"}, []string{"v1.1.9", "v1.2.0-rc.1"}},
+		{">= v1.2, < 2", ">= v1.2, < v2", []string{"v1.2.0", "v1.9.9"}, []string{"v1.1.0", "v2.0.0"}},
+		{"=v0.3.1", "= v0.3.1", []string{"v0.3.1"}, []string{"v0.3.0", "v0.3.2"}},
+		{"!= 1.0.0, <=1.1", "!= v1.0.0, <= v1.1", []string{"v0.9.0", "v1.0.1", "v1.1.0"},
+			[]string{"v1.0.0", "v1.1.1"}},
+		{"> 0.3", "> v0.3", []string{"v0.3.1", "v0.4.0"}, []string{"v0.3.0", "v0.2.0"}},
+		{"<1", "< v1", []string{"v0.3.1-0.20210428171433-ca9199676fb2"}, []string{"v1.0.0"}},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := parseVersionConstraint(tc.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.String() != tc.normalized {
+				t.Errorf("expected `%s`, got `%s`", tc.normalized, c.String())
+			}
+			for _, v := range tc.allowed {
+				if !c.allows(v) {
+					t.Errorf("%s should be allowed", v)
+				}
+			}
+			for _, v := range tc.rejected {
+				if c.allows(v) {
+					t.Errorf("%s should be rejected", v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVersionConstraintErrors(t *testing.T) {
+	for _, tc := range []struct{ constraint, err string }{
+		{"", "empty comparison in version constraint ``"},
+		{">= 1.0,", "empty comparison in version constraint `>= 1.0,`"},
+		{"latest", "invalid semantic version `vlatest` in version constraint `latest`"},
+		{"~> 1.0", "invalid semantic version `v~> 1.0` in version constraint `~> 1.0`"},
+		{">= 1.0.0.0", "invalid semantic version `v1.0.0.0` in version constraint `>= 1.0.0.0`"},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			if _, err := parseVersionConstraint(tc.constraint); err == nil || err.Error() != tc.err {
+				t.Errorf("expected error `%s`, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package qsbuild
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Interval is the time between two scans for changed files. Defaults to
+	// 500ms.
+	Interval time.Duration
+	// Run starts the compiled questscreen binary and restarts it after each
+	// successful rebuild that changed the binary. With Options.DevAssets,
+	// changed assets thus do not cause a restart.
+	Run bool
+}
+
+// watchPhase identifies the first phase that must run after a change. All
+// later phases run as well; they are skipped by the build cache if their
+// inputs did not change.
+type watchPhase int
+
+const (
+	watchNone watchPhase = iota
+	watchPlugins
+	watchWebUI
+	watchAssets
+	watchCompile
+)
+
+// fileState is what a scan records about a file to detect changes.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// generatedLine matches the header Go and other tools use to mark generated
+// files, see https://golang.org/s/generatedcode.
+var generatedLine = regexp.MustCompile(`^(//|#) Code generated .* DO NOT EDIT\.$`)
+
+// Watch builds QuestScreen and then watches its sources, rebuilding the
+// affected phases whenever files change. Failed rebuilds are reported and Watch
+// continues to wait for changes; it only returns when the Builder has been
+// closed or when the initialization fails.
+//
+// Files written by the phases themselves are recognized by their generated
+// code header and ignored; the assets/ directory and versioninfo/ are not
+// watched at all. Changes to go.mod, go.sum and the plugins file are only
+// reported since these files are loaded by Init.
+func (b *Builder) Watch(opts WatchOptions) error {
+	if err := b.Init(); err != nil {
+		return err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+	w := watcher{b: b}
+	w.files = w.scan()
+	if b.Build() == nil && opts.Run {
+		w.restartApp()
+	}
+	b.logInfo("watching for changes")
+	for {
+		time.Sleep(opts.Interval)
+		if b.isClosed() {
+			return nil
+		}
+		files := w.scan()
+		first := w.changes(files)
+		w.files = files
+		if first == watchNone {
+			continue
+		}
+		if b.rebuild(first) == nil && opts.Run {
+			w.restartApp()
+		}
+		b.logInfo("watching for changes")
+	}
+}
+
+// rebuild runs the given phase and all later ones.
+func (b *Builder) rebuild(first watchPhase) error {
+	phases := []func() error{b.Plugins, b.WebUI, b.Assets, b.Compile}
+	for _, phase := range phases[first-watchPlugins:] {
+		if err := phase(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) isClosed() bool {
+	b.cleanup.Lock()
+	defer b.cleanup.Unlock()
+	return b.cleanup.done
+}
+
+type watcher struct {
+	b     *Builder
+	files map[string]fileState
+	// stopApp stops the running questscreen binary.
+	stopApp func()
+	// appState is the state of the binary when it has been started.
+	appState fileState
+}
+
+// roots returns the files and directories to watch.
+func (w *watcher) roots() []string {
+	b := w.b
+	roots := []string{b.path("go.mod"), b.path("go.sum"), b.path("plugins"),
+		b.path("web")}
+	if b.opts.PluginFile != "" {
+		roots = append(roots, b.opts.PluginFile)
+	}
+	for _, p := range b.externalPlugins {
+		roots = append(roots, p.dir)
+	}
+	entries, err := os.ReadDir(b.root)
+	if err != nil {
+		b.logWarning("unable to read %s: %s", b.root, err.Error())
+		return roots
+	}
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case strings.HasPrefix(name, "."), name == "assets", name == "plugins",
+			name == "web", name == "versioninfo", name == "vendor":
+		default:
+			// only Go sources are relevant outside of web/ and plugins/.
+			if e.IsDir() || strings.HasSuffix(name, ".go") {
+				roots = append(roots, b.path(name))
+			}
+		}
+	}
+	return roots
+}
+
+// scan records the state of all watched files.
+func (w *watcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range w.roots() {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return files
+}
+
+// changes compares files with the previous scan and returns the first phase
+// affected by the changed files.
+func (w *watcher) changes(files map[string]fileState) watchPhase {
+	first := watchNone
+	check := func(path string, listed bool) {
+		if phase := w.classify(path, listed); phase != watchNone &&
+			(first == watchNone || phase < first) {
+			if w.b.opts.Verbose {
+				w.b.logVerbose("changed: " + path)
+			}
+			first = phase
+		}
+	}
+	for path, state := range files {
+		if prev, ok := w.files[path]; !ok || prev != state {
+			check(path, !ok)
+		}
+	}
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			check(path, true)
+		}
+	}
+	return first
+}
+
+// classify returns the first phase affected by a change of the given file.
+// listed is true if the file has been added or removed.
+func (w *watcher) classify(path string, listed bool) watchPhase {
+	b := w.b
+	if path == b.path("plugins", "plugins.yaml") || isGenerated(path) {
+		return watchNone
+	}
+	switch path {
+	case b.path("go.mod"), b.path("go.sum"), b.opts.PluginFile:
+		// these are only read during initialization.
+		b.logWarning("%s changed, restart to apply the change", path)
+		return watchNone
+	}
+	if rel, ok := relativeTo(b.path("web"), path); ok {
+		if within(rel, "assets") {
+			return watchAssets
+		}
+		return watchWebUI
+	}
+	pluginDirs := make([]string, 0, len(b.externalPlugins)+1)
+	pluginDirs = append(pluginDirs, b.path("plugins"))
+	for _, p := range b.externalPlugins {
+		pluginDirs = append(pluginDirs, p.dir)
+	}
+	for i, dir := range pluginDirs {
+		rel, ok := relativeTo(dir, path)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			// local plugins are subdirectories of plugins/.
+			parts := strings.SplitN(rel, string(filepath.Separator), 2)
+			if len(parts) < 2 {
+				return watchPlugins
+			}
+			rel = parts[1]
+		}
+		switch {
+		case within(rel, filepath.Join("web", "assets")):
+			if listed {
+				// the plugins phase lists the plugin's assets in plugins.yaml.
+				return watchPlugins
+			}
+			return watchAssets
+		case within(rel, "web"):
+			return watchWebUI
+		default:
+			// the manifest, templates and the Go sources, which include the
+			// config types inspected by the plugins phase.
+			return watchPlugins
+		}
+	}
+	if strings.HasSuffix(path, ".go") {
+		return watchCompile
+	}
+	return watchNone
+}
+
+// relativeTo returns path relative to dir if it is inside dir.
+func relativeTo(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// within checks whether the relative path rel is dir or inside dir.
+func within(rel, dir string) bool {
+	return rel == dir || strings.HasPrefix(rel, dir+string(filepath.Separator))
+}
+
+// isGenerated checks whether the file at path has a generated code header in
+// its first lines.
+func isGenerated(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 10 && scanner.Scan(); i++ {
+		if generatedLine.MatchString(scanner.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// restartApp stops the running questscreen binary, if any, and starts the
+// freshly compiled one unless the binary did not change. The binary is stopped
+// when the Builder is closed.
+func (w *watcher) restartApp() {
+	b := w.b
+	exeName := b.path("questscreen")
+	if runtime.GOOS == "windows" {
+		exeName = b.path("questscreen.exe")
+	}
+	info, err := os.Stat(exeName)
+	if err != nil {
+		b.logError("failed to start questscreen: %s", err.Error())
+		return
+	}
+	state := fileState{size: info.Size(), modTime: info.ModTime()}
+	if w.stopApp != nil && state == w.appState {
+		return
+	}
+	w.appState = state
+	if w.stopApp != nil {
+		b.logInfo("stopping questscreen")
+		w.stopApp()
+		w.stopApp = nil
+	}
+	app := b.command(exeName)
+	app.Stdout = os.Stdout
+	app.Stderr = os.Stderr
+	trackProcessGroup(app)
+	b.logInfo("starting questscreen")
+	if err := app.Start(); err != nil {
+		b.logError("failed to start questscreen: %s", err.Error())
+		return
+	}
+	w.stopApp = b.onCleanup(func() {
+		stopProcess(app.Process)
+		app.Wait()
+	})
+}
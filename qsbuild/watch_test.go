@@ -0,0 +1,70 @@
+package qsbuild
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	root := t.TempDir()
+	external := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"web/configitemsqmod0.go": "package web\n\n// Code generated by qs-build. DO NOT EDIT.\n",
+	})
+	var log strings.Builder
+	b := New(Options{PluginFile: filepath.Join(root, "plugins", "plugins.txt"),
+		Logger: recordingLogger{testLogger{t}, &log, true}})
+	b.root = root
+	b.externalPlugins = []pluginDescr{{dir: external}}
+	w := watcher{b: b}
+
+	for _, tc := range []struct {
+		path   string
+		listed bool
+		phase  watchPhase
+	}{
+		{"web/main/main.go", false, watchWebUI},
+		{"web/index.html", true, watchWebUI},
+		{"web/assets/style.css", false, watchAssets},
+		{"web/assets/new.css", true, watchAssets},
+		{"web/configitemsqmod0.go", false, watchNone},
+		{"plugins/plugins.yaml", false, watchNone},
+		{"plugins/README.md", false, watchPlugins},
+		{"plugins/base/questscreen-plugin.yaml", false, watchPlugins},
+		{"plugins/base/module/config.go", false, watchPlugins},
+		{"plugins/base/web/module/ui.go", false, watchWebUI},
+		{"plugins/base/web/assets/a.css", false, watchAssets},
+		// the plugins phase lists the assets of each plugin.
+		{"plugins/base/web/assets/b.css", true, watchPlugins},
+		{"app/main.go", false, watchCompile},
+		{"app/README.md", false, watchNone},
+		{filepath.Join(external, "module", "config.go"), false, watchPlugins},
+		{filepath.Join(external, "web", "assets", "x.png"), false, watchAssets},
+		{filepath.Join(external, "web", "assets", "y.png"), true, watchPlugins},
+		{filepath.Join(external, "web", "ui.go"), false, watchWebUI},
+		{filepath.Join(filepath.Dir(external), "other.go"), false, watchCompile},
+	} {
+		path := tc.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, filepath.FromSlash(path))
+		}
+		if actual := w.classify(path, tc.listed); actual != tc.phase {
+			t.Errorf("%s (listed: %v): expected phase %d, got %d", tc.path, tc.listed, tc.phase, actual)
+		}
+	}
+	if log.Len() != 0 {
+		t.Errorf("unexpected warnings:\n%s", log.String())
+	}
+
+	// files loaded by Init are only reported.
+	for _, name := range []string{"go.mod", "go.sum", filepath.Join("plugins", "plugins.txt")} {
+		log.Reset()
+		if actual := w.classify(filepath.Join(root, name), false); actual != watchNone {
+			t.Errorf("%s: expected no phase, got %d", name, actual)
+		}
+		if expected := filepath.Join(root, name) + " changed, restart to apply the change"; strings.TrimSpace(log.String()) != expected {
+			t.Errorf("%s: expected warning `%s`, got `%s`", name, expected, log.String())
+		}
+	}
+}
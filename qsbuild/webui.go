@@ -0,0 +1,192 @@
+package qsbuild
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/mod/modfile"
+)
+
+func copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// webUIInputs returns the inputs of buildWebUI: the sources processed by askew
+// and compiled into the web UI, which include the code generated by the plugins
+// phase. The files written to assets/ are outputs since the assets phase may
+// minify them.
+//
+// Fingerprint is a parameter since fingerprinting rewrites index.html in place:
+// toggling it runs the phase again, which restores the original index.html
+// when fingerprinting is turned off.
+func (b *Builder) webUIInputs() phaseInputs {
+	in := phaseInputs{params: []string{b.opts.Web, strconv.FormatBool(b.opts.Fingerprint)},
+		paths:   []string{"go.mod", "go.sum", b.goCmd, "web", "plugins"},
+		exclude: []string{filepath.Join("web", "assets")},
+		outputs: []string{filepath.Join("assets", "index.html")}}
+	if b.wasm {
+		in.outputs = append(in.outputs, filepath.Join("assets", "main.wasm"),
+			filepath.Join("assets", "wasm_exec.js"))
+	} else {
+		in.outputs = append(in.outputs, filepath.Join("assets", "main.js"),
+			filepath.Join("assets", "main.js.map"))
+	}
+	for _, p := range b.externalPlugins {
+		in.paths = append(in.paths, p.dir)
+	}
+	return in
+}
+
+func (b *Builder) buildWebUI() {
+	b.must(os.MkdirAll(b.path("assets"), 0755), "unable to create directory 'assets':")
+	defer b.protectModFiles()()
+	b.logInfo("running askew")
+	askewCmd := filepath.Join(b.goBin, "askew")
+	var cmd *exec.Cmd
+	if b.wasm {
+		cmd = b.command(askewCmd, "-o", "assets", "-b", "wasm", "-d", "plugins/plugins.yaml",
+			"--exclude", "app,assets,build-doc,data,display,main,shared", ".")
+	} else {
+		cmd = b.command(askewCmd, "-o", "assets", "-b", "gopherjs", "-d", "plugins/plugins.yaml",
+			"--exclude", "app,assets,build-doc,data,display,main,shared", ".")
+	}
+	// askew loads the packages with the go command, which must see the plugins
+	// available in the session's go.mod.
+	cmd.Env = append(os.Environ(), "GOFLAGS=-modfile="+b.sessionModPath)
+	b.runAndDumpIfVerbose(cmd,
+		func(err error, stderr string) {
+			b.logError("failed to run askew:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+	webMain := b.path("web", "main")
+
+	if b.wasm {
+		b.logInfo("compiling code to WASM")
+		cmd := b.goModCmd("build", "-o", "main.wasm")
+		cmd.Dir = webMain
+		cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+		b.runAndDumpIfVerbose(cmd, func(err error, stderr string) {
+			b.logError("failed to compile web UI:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+		})
+		goroot := b.runAndCheck(exec.Command(b.goCmd, "env", "GOROOT"), func(err error, stderr string) {
+			b.logError("while trying to get GOROOT:")
+			b.logError(err.Error())
+			b.writeErrorLines(stderr)
+			b.fail()
+		})
+		b.checkRename(filepath.Join(webMain, "main.wasm"), b.path("assets", "main.wasm"))
+		if err := copy(filepath.Join(goroot, "misc/wasm/wasm_exec.js"), b.path("assets", "wasm_exec.js")); err != nil {
+			b.logError("while copying 'wasm_exec.js:")
+			b.logError(err.Error())
+			b.fail()
+		}
+	} else {
+		b.logInfo("compiling code to JavaScript")
+		gopherjsRoot := b.runAndCheck(exec.Command("go1.12.16", "env", "GOROOT"),
+			func(err error, stderr string) {
+				b.logError("failed to query go1.12.16 for GOROOT:")
+				b.logError(err.Error())
+				b.writeErrorLines(stderr)
+			})
+
+		module, remove := b.gopherjsModule()
+		defer remove()
+		webMain = filepath.Join(module, "web", "main")
+		cmd := exec.Command(filepath.Join(b.goBin, "gopherjs"), "build")
+		cmd.Dir = webMain
+		if runtime.GOOS == "windows" {
+			cmd.Env = append(os.Environ(), "GOPHERJS_GOROOT="+gopherjsRoot, "GOOS=linux")
+		} else {
+			cmd.Env = append(os.Environ(), "GOPHERJS_GOROOT="+gopherjsRoot)
+		}
+		b.runAndDumpIfVerbose(cmd,
+			func(err error, stderr string) {
+				b.logError("failed to compile web UI:")
+				b.logError(err.Error())
+				b.writeErrorLines(stderr)
+			})
+
+		b.checkRename(filepath.Join(webMain, "main.js"), b.path("assets", "main.js"))
+		b.checkRename(filepath.Join(webMain, "main.js.map"), b.path("assets", "main.js.map"))
+	}
+}
+
+// moduleCopyExcludes are the entries of the root that are not copied by
+// gopherjsModule since they are not needed for compiling the web UI.
+var moduleCopyExcludes = map[string]struct{}{
+	".git": {}, cacheDir: {}, "assets": {}, "vendor": {}, "go.mod": {}, "go.sum": {}}
+
+// gopherjsModule creates a copy of the QuestScreen module for gopherjs. It is
+// run with go1.12, which does not support -modfile, so the copy contains the
+// session's go.mod and go.sum that make the external plugins available.
+// Relative paths in replace directives are made absolute since they are
+// relative to the root. The returned function removes the copy.
+func (b *Builder) gopherjsModule() (string, func()) {
+	// the copy is created inside the root so that the compiled files can be
+	// renamed into assets/.
+	b.must(os.MkdirAll(b.path(cacheDir), 0755), "failed to create "+cacheDir+":")
+	dir, err := ioutil.TempDir(b.path(cacheDir), "gopherjs")
+	b.must(err, "failed to create temporary directory:")
+	remove := b.onCleanup(func() {
+		os.RemoveAll(dir)
+	})
+	if b.opts.Verbose {
+		b.logVerbose("copying the QuestScreen module to " + dir)
+	}
+	entries, err := ioutil.ReadDir(b.root)
+	b.must(err, "failed to read "+b.root+":")
+	for _, entry := range entries {
+		if _, ok := moduleCopyExcludes[entry.Name()]; ok {
+			continue
+		}
+		src, dst := b.path(entry.Name()), filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			err = CopyDir(src, dst)
+		} else if entry.Mode()&os.ModeSymlink == 0 {
+			err = CopyFile(src, dst)
+		}
+		b.must(err, "failed to copy "+src+" to "+dst+":")
+	}
+
+	content, err := ioutil.ReadFile(b.sessionModPath)
+	b.must(err, "failed to read temporary go.mod:")
+	mod, err := modfile.Parse(b.sessionModPath, content, nil)
+	b.must(err, "failed to parse temporary go.mod:")
+	for _, r := range mod.Replace {
+		if r.New.Version == "" && !filepath.IsAbs(r.New.Path) {
+			b.must(mod.AddReplace(r.Old.Path, r.Old.Version,
+				filepath.Join(b.root, filepath.FromSlash(r.New.Path)), ""))
+		}
+	}
+	content, err = mod.Format()
+	b.must(err, "failed to format go.mod for gopherjs:")
+	b.must(ioutil.WriteFile(filepath.Join(dir, "go.mod"), content, 0644),
+		"failed to write go.mod for gopherjs:")
+	b.must(CopyFile(filepath.Join(b.sessionModDir, "go.sum"), filepath.Join(dir, "go.sum")),
+		"failed to write go.sum for gopherjs:")
+	return dir, remove
+}